@@ -2,7 +2,10 @@ package config
 
 import (
 	"fmt"
+	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -16,3 +19,37 @@ func Config(key string) string {
 
 	return os.Getenv(key)
 }
+
+// Duration reads a duration such as "90s" or "30m" from the config, falling
+// back to the given default when it is unset or invalid
+func Duration(key string, fallback time.Duration) time.Duration {
+	value := Config(key)
+	if value == "" {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		log.Printf("Invalid %s %q, using %s", key, value, fallback)
+		return fallback
+	}
+
+	return duration
+}
+
+// Int reads a whole number from the config, falling back to the given default
+// when it is unset or invalid
+func Int(key string, fallback int) int {
+	value := Config(key)
+	if value == "" {
+		return fallback
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid %s %q, using %d", key, value, fallback)
+		return fallback
+	}
+
+	return number
+}
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/vincemoke66/keyper-api/internals/model"
+)
+
+// backfill fills in the columns that rows created before they existed lack
+func backfill() {
+	// Records made before borrowers had a type were all made by students
+	err := DB.Exec(`UPDATE records r SET borrower_type = ?, borrower_id = r.student_id, borrower_name = r.student_name,
+		borrower_school_id = COALESCE((SELECT s.school_id FROM students s WHERE s.id = r.student_id LIMIT 1), '')
+		WHERE r.borrower_type IS NULL OR r.borrower_type = ''`, model.HolderTypeStudent).Error
+	if err != nil {
+		log.Println("Failed to backfill record borrowers", err)
+	}
+
+	// Borrowed keys take their holder from their latest borrow record
+	err = DB.Exec(`UPDATE keys k SET holder_type = r.borrower_type, holder_id = r.borrower_id,
+		holder_school_id = r.borrower_school_id, holder_name = r.borrower_name, borrowed_at = r.created_at
+		FROM (SELECT DISTINCT ON (key_id) * FROM records WHERE type = ? AND deleted_at IS NULL ORDER BY key_id, created_at DESC) r
+		WHERE r.key_id = k.id AND k.status = ? AND (k.holder_type IS NULL OR k.holder_type = '')`,
+		model.RecordTypeBorrow, model.KeyStatusBorrowed).Error
+	if err != nil {
+		log.Println("Failed to backfill key holders", err)
+	}
+
+	// Keys point to their latest record
+	err = DB.Exec(`UPDATE keys k SET last_record_id = r.id
+		FROM (SELECT DISTINCT ON (key_id) key_id, id FROM records WHERE deleted_at IS NULL ORDER BY key_id, created_at DESC) r
+		WHERE r.key_id = k.id AND k.last_record_id IS NULL`).Error
+	if err != nil {
+		log.Println("Failed to backfill last records of keys", err)
+	}
+
+	// Schedules made before they had a course and section take those of
+	// most of the students attending them
+	err = DB.Exec(`UPDATE schedules s SET course = a.course, section = a.section
+		FROM (SELECT DISTINCT ON (schedule_id) schedule_id, course, section FROM attendances
+			WHERE deleted_at IS NULL AND course <> '' AND section <> ''
+			GROUP BY schedule_id, course, section ORDER BY schedule_id, COUNT(*) DESC) a
+		WHERE a.schedule_id = s.id AND COALESCE(s.course, '') = '' AND COALESCE(s.section, '') = ''`).Error
+	if err != nil {
+		log.Println("Failed to backfill schedule courses", err)
+	}
+
+	// Keys made before key sets existed become the first copy of their room
+	var keys []model.Key
+	DB.Find(&keys, "key_set_id IS NULL")
+	for _, key := range keys {
+		keySet := model.KeySet{ID: uuid.New(), RoomID: key.RoomID, BuildingID: key.BuildingID, RoomName: key.RoomName, BuildingName: key.BuildingName}
+		if err := DB.Create(&keySet).Error; err != nil {
+			log.Println("Failed to create key set for key", key.RFID, err)
+			continue
+		}
+		DB.Model(&model.Key{}).Where("id = ?", key.ID).Updates(map[string]interface{}{"key_set_id": keySet.ID, "copy_number": 1})
+	}
+}
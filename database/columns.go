@@ -0,0 +1,41 @@
+package database
+
+import (
+	"log"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// migrateUUIDColumns turns the id columns of the models that were created as
+// text into uuid columns, so that they compare with the ids they point to.
+// AutoMigrate cannot do it as it does not convert the existing values.
+func migrateUUIDColumns(models ...interface{}) {
+	for _, m := range models {
+		stmt := &gorm.Statement{DB: DB}
+		if err := stmt.Parse(m); err != nil {
+			log.Println("Failed to read model", err)
+			continue
+		}
+
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" || field.TagSettings["TYPE"] != "uuid" {
+				continue
+			}
+
+			// Only the columns still stored as text are converted
+			var dataType string
+			DB.Raw(`SELECT data_type FROM information_schema.columns WHERE table_schema = CURRENT_SCHEMA() AND table_name = ? AND column_name = ?`,
+				stmt.Schema.Table, field.DBName).Scan(&dataType)
+			if dataType != "text" {
+				continue
+			}
+
+			column := clause.Column{Name: field.DBName}
+			err := DB.Exec(`ALTER TABLE ? ALTER COLUMN ? TYPE uuid USING NULLIF(?, '')::uuid`, clause.Table{Name: stmt.Schema.Table}, column, column).Error
+			if err != nil {
+				log.Println("Failed to migrate "+stmt.Schema.Table+"."+field.DBName+" to uuid", err)
+			}
+		}
+	}
+}
@@ -39,13 +39,40 @@ func ConnectDB() {
 
 	fmt.Println("Connection Opened to Database")
 
+	Migrate()
+}
+
+// Migrate brings the tables of DB up to date with the models
+func Migrate() {
+	// Turn the id columns created as text into uuid before migrating
+	migrateUUIDColumns(&model.Room{}, &model.Cabinet{}, &model.KeySet{}, &model.Key{}, &model.Record{},
+		&model.Attendance{}, &model.Reservation{}, &model.WaitlistEntry{}, &model.BorrowLimitException{},
+		&model.RoomAccessGrant{}, &model.Suspension{}, &model.KeyAudit{}, &model.KeyAuditFinding{},
+		&model.AccessGroup{}, &model.AccessGroupRoom{})
+
 	// Migrate the database
 	DB.AutoMigrate(&model.Student{})
 	DB.AutoMigrate(&model.Instructor{})
+	DB.AutoMigrate(&model.Staff{}, &model.Guest{})
 	DB.AutoMigrate(&model.Building{}, &model.Room{})
-	DB.AutoMigrate(&model.Key{})
+	DB.AutoMigrate(&model.Cabinet{})
+	DB.AutoMigrate(&model.AccessGroup{}, &model.AccessGroupRoom{})
+	DB.AutoMigrate(&model.KeySet{}, &model.Key{})
 	DB.AutoMigrate(&model.Record{})
 	DB.AutoMigrate(&model.Schedule{})
 	DB.AutoMigrate(&model.Attendance{})
+	DB.AutoMigrate(&model.Reservation{})
+	DB.AutoMigrate(&model.WaitlistEntry{})
+	DB.AutoMigrate(&model.BorrowLimitException{})
+	DB.AutoMigrate(&model.RoomAccessGrant{})
+	DB.AutoMigrate(&model.Suspension{}, &model.SuspensionEvent{})
+	DB.AutoMigrate(&model.KeyAudit{}, &model.KeyAuditFinding{})
+	DB.AutoMigrate(&model.IdempotentResponse{})
+
+	createIndexes()
+
+	// Fill in the data added to existing rows
+	backfill()
+
 	fmt.Println("Database Migrated")
 }
@@ -0,0 +1,15 @@
+package database
+
+// createIndexes adds the indexes that span columns of gorm.Model and so
+// cannot be declared on the models. Every one of them serves a query made on
+// each tap, keeping its cost the same however many records there are.
+func createIndexes() {
+	// The records of a key, newest first
+	DB.Exec(`CREATE INDEX IF NOT EXISTS idx_records_key_created ON records (key_id, created_at DESC)`)
+	// The late returns and lost keys of a borrower, for limits and suspensions
+	DB.Exec(`CREATE INDEX IF NOT EXISTS idx_records_borrower ON records (borrower_type, borrower_id, type, created_at)`)
+	// Pages of records, newest first
+	DB.Exec(`CREATE INDEX IF NOT EXISTS idx_records_created ON records (created_at DESC, id DESC)`)
+	// The reservation holding a key right now
+	DB.Exec(`CREATE INDEX IF NOT EXISTS idx_reservations_key ON reservations (key_id, status, starts_at)`)
+}
@@ -0,0 +1,314 @@
+package accessGroupHandler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/vincemoke66/keyper-api/database"
+	recordHandler "github.com/vincemoke66/keyper-api/internals/handlers/record"
+	"github.com/vincemoke66/keyper-api/internals/model"
+	"gorm.io/gorm"
+)
+
+// AccessGroupToAdd is the body of a created or updated access group
+type AccessGroupToAdd struct {
+	Name         string                 `json:"name"`
+	BuildingName string                 `json:"building_name"`
+	Scope        model.AccessGroupScope `json:"scope"`
+	Floor        int                    `json:"floor"`
+	RoomNames    []string               `json:"room_names"`
+}
+
+// GetAccessGroups func gets all existing access groups
+// @Description Get all existing access groups
+// @Tags AccessGroup
+// @Accept json
+// @Produce json
+// @Success 200 {array} model.AccessGroup
+// @router /api/access-group [get]
+func GetAccessGroups(c *fiber.Ctx) error {
+	db := database.DB
+	var groups []model.AccessGroup
+
+	// find all access groups in the database
+	db.Find(&groups)
+
+	// If no access group is present return an error
+	if len(groups) == 0 {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "No Access Groups data found", "data": nil})
+	}
+
+	// Return access groups
+	return c.JSON(fiber.Map{"status": "success", "message": "Access Groups Found", "data": groups})
+}
+
+// CreateAccessGroup func create an access group
+// @Description Create an Access Group of the rooms opened by a master key. The building scope covers every room of the building, the floor scope the rooms on floor and the rooms scope the rooms in room_names.
+// @Tags AccessGroup
+// @Accept json
+// @Produce json
+// @Param name body string true "name"
+// @Param building_name body string true "building_name"
+// @Param scope body string true "scope"
+// @Param floor body int false "floor"
+// @Param room_names body []string false "room_names"
+// @Success 200 {object} model.AccessGroup
+// @router /api/access-group [post]
+func CreateAccessGroup(c *fiber.Ctx) error {
+	db := database.DB
+	group := new(model.AccessGroup)
+
+	group_to_add := new(AccessGroupToAdd)
+
+	// Parse the body to the access group object
+	err := c.BodyParser(group_to_add)
+	// Return parse error if any
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Review your input", "data": err})
+	}
+	// Return invalid name if empty or null
+	if group_to_add.Name == uuid.Nil.String() || group_to_add.Name == "" {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Invalid Access Group Name", "data": err})
+	}
+
+	// Create a temporary access group data
+	var storedGroup model.AccessGroup
+
+	// Find the access group with the given name
+	db.Find(&storedGroup, "name = ?", group_to_add.Name)
+
+	// If access group name exists, return an error
+	if storedGroup.ID != uuid.Nil {
+		return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Access Group with the same name already exist.", "data": nil})
+	}
+
+	// Create a temporary building data
+	var storedBuilding model.Building
+	db.Find(&storedBuilding, "name = ?", group_to_add.BuildingName)
+	// If building does not exist, return an error
+	if storedBuilding.ID == uuid.Nil {
+		return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Building does not exist.", "data": nil})
+	}
+
+	// Add a uuid to the new access group
+	group.ID = uuid.New()
+	group.Name = group_to_add.Name
+	group.BuildingID = storedBuilding.ID
+	group.BuildingName = storedBuilding.Name
+
+	// Create the access group with its rooms and return error if encountered
+	err = db.Transaction(func(tx *gorm.DB) error {
+		err := setScope(tx, group, group_to_add)
+		if err != nil {
+			return err
+		}
+		return tx.Create(&group).Error
+	})
+	if err != nil {
+		return recordHandler.RespondErrorWith(c, err, "Could not create access group")
+	}
+
+	// Return the created access group
+	return c.JSON(fiber.Map{"status": "success", "message": "Access Group created", "data": group})
+}
+
+// GetAccessGroup func get one access group by name
+// @Description Get one access group by name along with the rooms it covers
+// @Tags AccessGroup
+// @Accept json
+// @Produce json
+// @Success 200 {object} model.AccessGroup
+// @router /api/access-group/{name} [get]
+func GetAccessGroup(c *fiber.Ctx) error {
+	db := database.DB
+	var group model.AccessGroup
+
+	// Read the param name
+	name := c.Params("name")
+
+	// Find the access group with the given name
+	db.Find(&group, "name = ?", name)
+
+	// If no such access group present, return an error
+	if group.ID == uuid.Nil {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Access Group not found", "data": nil})
+	}
+
+	// Find the rooms the group covers
+	var rooms []model.Room
+	recordHandler.CoveredRooms(db, group).Order("name").Find(&rooms)
+
+	// Find the master keys of the group
+	var keys []model.Key
+	db.Order("copy_number").Find(&keys, "access_group_id = ?", group.ID)
+
+	// Return the access group with the specified name
+	return c.JSON(fiber.Map{"status": "success", "message": "Access Group Found", "data": fiber.Map{"access_group": group, "rooms": rooms, "keys": keys}})
+}
+
+// UpdateAccessGroup update an access group by name
+// @Description Update an Access Group by name, replacing the rooms it covers
+// @Tags AccessGroup
+// @Accept json
+// @Produce json
+// @Param name body string true "name"
+// @Param scope body string true "scope"
+// @Param floor body int false "floor"
+// @Param room_names body []string false "room_names"
+// @Success 200 {object} model.AccessGroup
+// @router /api/access-group/{name} [put]
+func UpdateAccessGroup(c *fiber.Ctx) error {
+	db := database.DB
+	var group model.AccessGroup
+
+	// Read the access group name param
+	group_name := c.Params("name")
+
+	// Find the access group with the given name param
+	db.Find(&group, "name = ?", group_name)
+
+	// If no such access group, return an error
+	if group.ID == uuid.Nil {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Access Group not found", "data": nil})
+	}
+
+	// Store the body containing the updated data
+	var group_to_update AccessGroupToAdd
+	err := c.BodyParser(&group_to_update)
+
+	// Return parsing error if encountered
+	if err != nil {
+		return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Review your input", "data": err})
+	}
+	if group_to_update.Name == "" {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Invalid Access Group Name", "data": nil})
+	}
+
+	// If another access group has the new name, return an error
+	if group_to_update.Name != group.Name {
+		var storedGroup model.AccessGroup
+		db.Find(&storedGroup, "name = ?", group_to_update.Name)
+		if storedGroup.ID != uuid.Nil {
+			return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Access Group with the same name already exist.", "data": nil})
+		}
+	}
+
+	// Edit the access group
+	group.Name = group_to_update.Name
+
+	// Save the changes along with the new rooms of the group
+	err = db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("access_group_id = ?", group.ID).Delete(&model.AccessGroupRoom{}).Error
+		if err != nil {
+			return err
+		}
+		err = setScope(tx, &group, &group_to_update)
+		if err != nil {
+			return err
+		}
+		err = tx.Save(&group).Error
+		if err != nil {
+			return err
+		}
+
+		// Master keys are named after their group
+		return tx.Model(&model.Key{}).Where("access_group_id = ?", group.ID).Update("room_name", group.Name).Error
+	})
+	if err != nil {
+		return recordHandler.RespondErrorWith(c, err, "Could not update access group")
+	}
+
+	// Return the updated access group
+	return c.JSON(fiber.Map{"status": "success", "message": "Access Group Updated", "data": group})
+}
+
+// DeleteAccessGroup delete an access group by name
+// @Description Delete an Access Group by name
+// @Tags AccessGroup
+// @Accept json
+// @Produce json
+// @Success 200
+// @router /api/access-group/{name} [delete]
+func DeleteAccessGroup(c *fiber.Ctx) error {
+	db := database.DB
+	var group model.AccessGroup
+
+	// Read the access group name param
+	group_name := c.Params("name")
+
+	// Find the access group with the given name param
+	db.Find(&group, "name = ?", group_name)
+
+	// If no such access group present return an error
+	if group.ID == uuid.Nil {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Access Group not found", "data": nil})
+	}
+
+	// If master keys still open the group, return an error
+	var keys int64
+	db.Model(&model.Key{}).Where("access_group_id = ?", group.ID).Count(&keys)
+	if keys > 0 {
+		return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Access Group still has keys.", "data": nil})
+	}
+
+	// Delete the access group along with its rooms
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("access_group_id = ?", group.ID).Delete(&model.AccessGroupRoom{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(&group).Error
+	})
+
+	// Return error if encountered
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to delete access group", "data": nil})
+	}
+
+	// Return success message
+	return c.JSON(fiber.Map{"status": "success", "message": "Access Group Deleted"})
+}
+
+// setScope sets the scope of the group, adding the listed rooms of a rooms scope
+func setScope(tx *gorm.DB, group *model.AccessGroup, input *AccessGroupToAdd) error {
+	group.Scope = input.Scope
+	group.Floor = 0
+
+	switch input.Scope {
+	case model.AccessGroupScopeBuilding:
+		return nil
+	case model.AccessGroupScopeFloor:
+		group.Floor = input.Floor
+		return nil
+	case model.AccessGroupScopeRooms:
+	default:
+		return &recordHandler.RecordError{Status: 400, Message: "Invalid scope"}
+	}
+
+	if len(input.RoomNames) == 0 {
+		return &recordHandler.RecordError{Status: 400, Message: "Review your input"}
+	}
+
+	seen := map[string]bool{}
+	for _, name := range input.RoomNames {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		// Every listed room must be in the building of the group
+		var storedRoom model.Room
+		err := tx.Find(&storedRoom, "name = ? AND building_id = ?", name, group.BuildingID).Error
+		if err != nil {
+			return err
+		}
+		if storedRoom.ID == uuid.Nil {
+			return &recordHandler.RecordError{Status: 409, Message: "Room " + name + " does not exist in " + group.BuildingName + "."}
+		}
+
+		err = tx.Create(&model.AccessGroupRoom{ID: uuid.New(), AccessGroupID: group.ID, RoomID: storedRoom.ID, RoomName: storedRoom.Name}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package analyticsHandler
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/vincemoke66/keyper-api/database"
+	recordHandler "github.com/vincemoke66/keyper-api/internals/handlers/record"
+	"github.com/vincemoke66/keyper-api/internals/model"
+	"gorm.io/gorm"
+)
+
+const defaultTop = 10
+
+// RoomUsage is how many times the keys of a room were borrowed
+type RoomUsage struct {
+	RoomName     string `json:"room_name"`
+	BuildingName string `json:"building_name"`
+	Borrows      int64  `json:"borrows"`
+}
+
+// BuildingDuration is how long the keys of a building are held once returned
+type BuildingDuration struct {
+	BuildingName        string  `json:"building_name"`
+	Returns             int64   `json:"returns"`
+	AverageMinutes      float64 `json:"average_minutes"`
+	Percentile95Minutes float64 `json:"p95_minutes"`
+}
+
+// TimeSlot is how many borrows started in an hour of the day or day of the week
+type TimeSlot struct {
+	Slot    int    `json:"slot"`
+	Label   string `json:"label"`
+	Borrows int64  `json:"borrows"`
+}
+
+// BorrowerUsage is how many times a borrower borrowed a key
+type BorrowerUsage struct {
+	BorrowerType     model.HolderType `json:"borrower_type"`
+	BorrowerSchoolID string           `json:"borrower_school_id"`
+	BorrowerName     string           `json:"borrower_name"`
+	Borrows          int64            `json:"borrows"`
+}
+
+// KeyAnalytics are the usage statistics of keys
+type KeyAnalytics struct {
+	TotalBorrows       int64              `json:"total_borrows"`
+	MostBorrowedRooms  []RoomUsage        `json:"most_borrowed_rooms"`
+	LeastBorrowedRooms []RoomUsage        `json:"least_borrowed_rooms"`
+	DurationByBuilding []BuildingDuration `json:"duration_by_building"`
+	BorrowsByHour      []TimeSlot         `json:"borrows_by_hour"`
+	BorrowsByWeekday   []TimeSlot         `json:"borrows_by_weekday"`
+	TopBorrowers       []BorrowerUsage    `json:"top_borrowers"`
+}
+
+// GetKeyAnalytics func gets usage statistics of keys
+// @Description Gets the most and least borrowed rooms, borrow durations per building, borrows by hour and weekday and the top borrowers
+// @Tags Analytics
+// @Accept json
+// @Produce json
+// @Param from query string false "from"
+// @Param to query string false "to"
+// @Param building query string false "building"
+// @Param top query int false "top"
+// @Success 200 {object} KeyAnalytics
+// @router /api/analytics/keys [get]
+func GetKeyAnalytics(c *fiber.Ctx) error {
+	db := database.DB
+
+	// Read the filters, the same ones the record list takes
+	filter, err := recordHandler.ParseRecordFilter(c)
+	if err != nil {
+		return recordHandler.RespondErrorWith(c, err, "Could not read analytics")
+	}
+	top, err := strconv.Atoi(c.Query("top", strconv.Itoa(defaultTop)))
+	if err != nil || top < 1 {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Invalid top", "data": nil})
+	}
+
+	// Every statistic is computed over the borrows paired with their returns
+	borrows := func() *gorm.DB {
+		return db.Table("(?) AS borrows", recordHandler.BorrowStats(db, filter))
+	}
+
+	analytics := KeyAnalytics{}
+	borrows().Count(&analytics.TotalBorrows)
+
+	// Rooms by number of borrows, counting rooms whose keys were never borrowed
+	var usage []RoomUsage
+	err = borrows().Select("room_name, building_name, COUNT(*) AS borrows").
+		Group("room_name, building_name").Scan(&usage).Error
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Could not compute analytics", "data": err})
+	}
+	analytics.MostBorrowedRooms, analytics.LeastBorrowedRooms = rankRooms(db, filter, usage, top)
+
+	// Average and 95th percentile of how long returned keys were held
+	err = borrows().Select(`building_name, COUNT(*) AS returns,
+		AVG(EXTRACT(EPOCH FROM returned_at - borrowed_at)) / 60 AS average_minutes,
+		PERCENTILE_CONT(0.95) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM returned_at - borrowed_at)) / 60 AS percentile95_minutes`).
+		Where("returned_at IS NOT NULL").Group("building_name").Order("building_name").
+		Scan(&analytics.DurationByBuilding).Error
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Could not compute analytics", "data": err})
+	}
+
+	// Borrows by hour of the day and day of the week
+	analytics.BorrowsByHour = make([]TimeSlot, 24)
+	for hour := range analytics.BorrowsByHour {
+		analytics.BorrowsByHour[hour] = TimeSlot{Slot: hour, Label: time.Date(0, 1, 1, hour, 0, 0, 0, time.Local).Format("15:04")}
+	}
+	analytics.BorrowsByWeekday = make([]TimeSlot, 7)
+	for day := range analytics.BorrowsByWeekday {
+		analytics.BorrowsByWeekday[day] = TimeSlot{Slot: day, Label: time.Weekday(day).String()}
+	}
+	for part, slots := range map[string][]TimeSlot{"HOUR": analytics.BorrowsByHour, "DOW": analytics.BorrowsByWeekday} {
+		var counts []TimeSlot
+		err = borrows().Select("CAST(EXTRACT(" + part + " FROM borrowed_at) AS INTEGER) AS slot, COUNT(*) AS borrows").
+			Group("slot").Scan(&counts).Error
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Could not compute analytics", "data": err})
+		}
+		for _, count := range counts {
+			if count.Slot >= 0 && count.Slot < len(slots) {
+				slots[count.Slot].Borrows = count.Borrows
+			}
+		}
+	}
+
+	// The borrowers with the most borrows
+	err = borrows().Select("borrower_type, borrower_school_id, borrower_name, COUNT(*) AS borrows").
+		Group("borrower_type, borrower_school_id, borrower_name").Order("borrows DESC").Limit(top).
+		Scan(&analytics.TopBorrowers).Error
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Could not compute analytics", "data": err})
+	}
+
+	// Return the analytics
+	return c.JSON(fiber.Map{"status": "success", "message": "Key Analytics Found", "data": analytics})
+}
+
+// rankRooms returns the top most and least borrowed rooms. Rooms with keys
+// that match the filter but were never borrowed count as zero borrows.
+func rankRooms(db *gorm.DB, filter recordHandler.RecordFilter, usage []RoomUsage, top int) ([]RoomUsage, []RoomUsage) {
+	var keySets []model.KeySet
+	query := db.Order("room_name ASC")
+	if filter.BuildingName != "" {
+		query = query.Where("building_name = ?", filter.BuildingName)
+	}
+	if filter.RoomName != "" {
+		query = query.Where("room_name = ?", filter.RoomName)
+	}
+	query.Find(&keySets)
+
+	counted := map[string]bool{}
+	for _, room := range usage {
+		counted[room.BuildingName+"/"+room.RoomName] = true
+	}
+	for _, keySet := range keySets {
+		if !counted[keySet.BuildingName+"/"+keySet.RoomName] {
+			usage = append(usage, RoomUsage{RoomName: keySet.RoomName, BuildingName: keySet.BuildingName})
+		}
+	}
+
+	sort.SliceStable(usage, func(i, j int) bool { return usage[i].Borrows > usage[j].Borrows })
+
+	if top > len(usage) {
+		top = len(usage)
+	}
+	most := usage[:top]
+	least := make([]RoomUsage, 0, top)
+	for i := len(usage) - 1; len(least) < top; i-- {
+		least = append(least, usage[i])
+	}
+	return most, least
+}
@@ -116,11 +116,16 @@ func CreateAttendance(c *fiber.Ctx) error {
 
 // Function to check if the input matches a schedule
 func CheckSchedule(inputTime string, roomName string) (bool, model.Schedule, error) {
-	db := database.DB
+	return CheckScheduleBetween(database.DB, inputTime, inputTime, roomName)
+}
+
+// CheckScheduleBetween checks if a schedule in the room runs at some point
+// between fromTime and toTime. Extra conditions can be set on db beforehand.
+func CheckScheduleBetween(db *gorm.DB, fromTime string, toTime string, roomName string) (bool, model.Schedule, error) {
 	var schedule model.Schedule
 
 	// Query to check if there is a matching schedule
-	err := db.Where("start_time <= ? AND end_time >= ? AND room_name = ?", inputTime, inputTime, roomName).First(&schedule).Error
+	err := db.Where("start_time <= ? AND end_time >= ? AND room_name = ?", toTime, fromTime, roomName).First(&schedule).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			// No matching schedule found
@@ -37,6 +37,12 @@ func GetBuildings(c *fiber.Ctx) error {
 // @Produce json
 // @Param name body string true "name"
 // @Param abbrv body string true "abbrv"
+// @Param default_borrow_minutes body int false "default_borrow_minutes"
+// @Param schedule_required body bool false "schedule_required"
+// @Param schedule_grace_before body int false "schedule_grace_before"
+// @Param schedule_grace_after body int false "schedule_grace_after"
+// @Param max_keys_per_student body int false "max_keys_per_student"
+// @Param late_return_cooldown_minutes body int false "late_return_cooldown_minutes"
 // @Success 200 {object} model.Building
 // @router /api/building [post]
 func CreateBuilding(c *fiber.Ctx) error {
@@ -112,6 +118,12 @@ func GetBuilding(c *fiber.Ctx) error {
 // @Produce json
 // @Param name body string true "name"
 // @Param abbrv body string true "abbrv"
+// @Param default_borrow_minutes body int false "default_borrow_minutes"
+// @Param schedule_required body bool false "schedule_required"
+// @Param schedule_grace_before body int false "schedule_grace_before"
+// @Param schedule_grace_after body int false "schedule_grace_after"
+// @Param max_keys_per_student body int false "max_keys_per_student"
+// @Param late_return_cooldown_minutes body int false "late_return_cooldown_minutes"
 // @Success 200 {object} model.Building
 // @router /api/building/{name} [put]
 func UpdateBuilding(c *fiber.Ctx) error {
@@ -119,6 +131,16 @@ func UpdateBuilding(c *fiber.Ctx) error {
 	type updateBuilding struct {
 		Name  string `json:"name"`
 		Abbrv string `json:"abbrv"`
+		// Loan length, nil keeps the building as it is
+		DefaultBorrowMinutes *int `json:"default_borrow_minutes"`
+		// Schedule policy, nil keeps the building as it is
+		ScheduleRequired    *bool `json:"schedule_required"`
+		ScheduleGraceBefore *int  `json:"schedule_grace_before"`
+		ScheduleGraceAfter  *int  `json:"schedule_grace_after"`
+		// Borrow limits, zero follows the global limits and nil keeps the
+		// building as it is
+		MaxKeysPerStudent         *int `json:"max_keys_per_student"`
+		LateReturnCooldownMinutes *int `json:"late_return_cooldown_minutes"`
 	}
 
 	db := database.DB
@@ -147,6 +169,24 @@ func UpdateBuilding(c *fiber.Ctx) error {
 	// Edit the building
 	building.Name = updateBuildingData.Name
 	building.Abbrv = updateBuildingData.Abbrv
+	if updateBuildingData.DefaultBorrowMinutes != nil {
+		building.DefaultBorrowMinutes = *updateBuildingData.DefaultBorrowMinutes
+	}
+	if updateBuildingData.ScheduleRequired != nil {
+		building.ScheduleRequired = *updateBuildingData.ScheduleRequired
+	}
+	if updateBuildingData.ScheduleGraceBefore != nil {
+		building.ScheduleGraceBefore = *updateBuildingData.ScheduleGraceBefore
+	}
+	if updateBuildingData.ScheduleGraceAfter != nil {
+		building.ScheduleGraceAfter = *updateBuildingData.ScheduleGraceAfter
+	}
+	if updateBuildingData.MaxKeysPerStudent != nil {
+		building.MaxKeysPerStudent = *updateBuildingData.MaxKeysPerStudent
+	}
+	if updateBuildingData.LateReturnCooldownMinutes != nil {
+		building.LateReturnCooldownMinutes = *updateBuildingData.LateReturnCooldownMinutes
+	}
 
 	// Save the Changes
 	db.Save(&building)
@@ -0,0 +1,313 @@
+package cabinetHandler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/vincemoke66/keyper-api/database"
+	"github.com/vincemoke66/keyper-api/internals/model"
+)
+
+// GetCabinets func gets all existing cabinets
+// @Description Get all existing cabinets
+// @Tags Cabinet
+// @Accept json
+// @Produce json
+// @Success 200 {array} model.Cabinet
+// @router /api/cabinet [get]
+func GetCabinets(c *fiber.Ctx) error {
+	db := database.DB
+	var cabinet []model.Cabinet
+
+	// find all cabinets in the database
+	db.Find(&cabinet)
+
+	// If no cabinet is present return an error
+	if len(cabinet) == 0 {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "No Cabinets data found", "data": nil})
+	}
+
+	// Return cabinets
+	return c.JSON(fiber.Map{"status": "success", "message": "Cabinets Found", "data": cabinet})
+}
+
+// CreateCabinet func create a cabinet
+// @Description Create a Cabinet
+// @Tags Cabinet
+// @Accept json
+// @Produce json
+// @Param name body string true "name"
+// @Param building_name body string true "building_name"
+// @Param location body string false "location"
+// @Param reader_id body string false "reader_id"
+// @Param return_anywhere body bool false "return_anywhere"
+// @Success 200 {object} model.Cabinet
+// @router /api/cabinet [post]
+func CreateCabinet(c *fiber.Ctx) error {
+	db := database.DB
+	cabinet := new(model.Cabinet)
+
+	// Parse the body to the cabinet object
+	err := c.BodyParser(cabinet)
+	// Return parse error if any
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Review your input", "data": err})
+	}
+	// Return invalid name if empty or null
+	if cabinet.Name == uuid.Nil.String() || cabinet.Name == "" {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Invalid Cabinet Name", "data": err})
+	}
+
+	// Create a temporary cabinet data
+	var storedCabinet model.Cabinet
+
+	// Find the cabinet with the given name
+	db.Find(&storedCabinet, "name = ?", cabinet.Name)
+
+	// If cabinet name exists, return an error
+	if storedCabinet.ID != uuid.Nil {
+		return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Cabinet with the same name already exist.", "data": nil})
+	}
+
+	// Find the cabinet with the given reader
+	if cabinet.ReaderID != "" {
+		db.Find(&storedCabinet, "reader_id = ?", cabinet.ReaderID)
+		// If the reader is already on a cabinet, return an error
+		if storedCabinet.ID != uuid.Nil {
+			return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Cabinet with the same reader already exist.", "data": nil})
+		}
+	}
+
+	// Create a temporary building data
+	var storedBuilding model.Building
+	db.Find(&storedBuilding, "name = ?", cabinet.BuildingName)
+	// If building does not exist, return an error
+	if storedBuilding.ID == uuid.Nil {
+		return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Building does not exist.", "data": nil})
+	}
+
+	// Add a uuid to the new cabinet
+	cabinet.ID = uuid.New()
+	cabinet.BuildingID = storedBuilding.ID
+
+	// Create the Cabinet and return error if encountered
+	err = db.Create(&cabinet).Error
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Could not create cabinet", "data": err})
+	}
+
+	// Return the created cabinet
+	return c.JSON(fiber.Map{"status": "success", "message": "Cabinet created", "data": cabinet})
+}
+
+// GetCabinet func get one cabinet by name
+// @Description Get one cabinet by name
+// @Tags Cabinet
+// @Accept json
+// @Produce json
+// @Success 200 {object} model.Cabinet
+// @router /api/cabinet/{name} [get]
+func GetCabinet(c *fiber.Ctx) error {
+	db := database.DB
+	var cabinet model.Cabinet
+
+	// Read the param name
+	name := c.Params("name")
+
+	// Find the cabinet with the given name
+	db.Find(&cabinet, "name = ?", name)
+
+	// If no such cabinet present, return an error
+	if cabinet.ID == uuid.Nil {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Cabinet not found", "data": nil})
+	}
+
+	// Return the cabinet with the specified name
+	return c.JSON(fiber.Map{"status": "success", "message": "Cabinet Found", "data": cabinet})
+}
+
+// CabinetContents is what a cabinet holds right now and where its other keys are
+type CabinetContents struct {
+	Cabinet model.Cabinet `json:"cabinet"`
+	// Keys hanging in the cabinet, its own and those returned from other cabinets
+	Present []model.Key `json:"present"`
+	// Keys of the cabinet that are borrowed
+	Out []model.Key `json:"out"`
+	// Keys of the cabinet that were returned to another cabinet
+	Elsewhere []model.Key `json:"elsewhere"`
+	// Keys of the cabinet that are lost, in maintenance or out of use
+	Unavailable []model.Key `json:"unavailable"`
+}
+
+// GetCabinetKeys func get the current contents of a cabinet
+// @Description Get the keys hanging in a cabinet and where the rest of its keys are
+// @Tags Cabinet
+// @Accept json
+// @Produce json
+// @Success 200 {object} CabinetContents
+// @router /api/cabinet/{name}/keys [get]
+func GetCabinetKeys(c *fiber.Ctx) error {
+	db := database.DB
+	var cabinet model.Cabinet
+
+	// Read the param name
+	name := c.Params("name")
+
+	// Find the cabinet with the given name
+	db.Find(&cabinet, "name = ?", name)
+
+	// If no such cabinet present, return an error
+	if cabinet.ID == uuid.Nil {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Cabinet not found", "data": nil})
+	}
+
+	// Find the keys of the cabinet and the keys returned to it
+	var keys []model.Key
+	db.Order("room_name, copy_number").Find(&keys, "cabinet_id = ? OR current_cabinet_id = ?", cabinet.ID, cabinet.ID)
+
+	contents := CabinetContents{Cabinet: cabinet, Present: []model.Key{}, Out: []model.Key{}, Elsewhere: []model.Key{}, Unavailable: []model.Key{}}
+	for _, key := range keys {
+		// Keys never returned to a cabinet hang in their own
+		location := key.CurrentCabinetID
+		if location == uuid.Nil {
+			location = key.CabinetID
+		}
+
+		switch {
+		case key.Status == model.KeyStatusAvailable && location == cabinet.ID:
+			contents.Present = append(contents.Present, key)
+		case key.CabinetID != cabinet.ID:
+			// Keys of other cabinets only count while they hang here
+		case key.Status == model.KeyStatusBorrowed:
+			contents.Out = append(contents.Out, key)
+		case key.Status == model.KeyStatusAvailable:
+			contents.Elsewhere = append(contents.Elsewhere, key)
+		default:
+			contents.Unavailable = append(contents.Unavailable, key)
+		}
+	}
+
+	// Return the contents of the cabinet
+	return c.JSON(fiber.Map{"status": "success", "message": "Cabinet Keys Found", "data": contents})
+}
+
+// UpdateCabinet update a cabinet by name
+// @Description Update a Cabinet by name
+// @Tags Cabinet
+// @Accept json
+// @Produce json
+// @Param name body string true "name"
+// @Param location body string false "location"
+// @Param reader_id body string false "reader_id"
+// @Param return_anywhere body bool false "return_anywhere"
+// @Success 200 {object} model.Cabinet
+// @router /api/cabinet/{name} [put]
+func UpdateCabinet(c *fiber.Ctx) error {
+	// Create a struct for updating only writable values
+	type updateCabinet struct {
+		Name           string `json:"name"`
+		Location       string `json:"location"`
+		ReaderID       string `json:"reader_id"`
+		ReturnAnywhere bool   `json:"return_anywhere"`
+	}
+
+	db := database.DB
+	var cabinet model.Cabinet
+
+	// Read the cabinet name param
+	cabinet_name := c.Params("name")
+
+	// Find the cabinet with the given name param
+	db.Find(&cabinet, "name = ?", cabinet_name)
+
+	// If no such cabinet, return an error
+	if cabinet.ID == uuid.Nil {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Cabinet not found", "data": nil})
+	}
+
+	// Store the body containing the updated data
+	var updateCabinetData updateCabinet
+	err := c.BodyParser(&updateCabinetData)
+
+	// Return parsing error if encountered
+	if err != nil {
+		return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Review your input", "data": err})
+	}
+	if updateCabinetData.Name == "" {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Invalid Cabinet Name", "data": nil})
+	}
+
+	// If another cabinet has the new name, return an error
+	if updateCabinetData.Name != cabinet.Name {
+		var storedCabinet model.Cabinet
+		db.Find(&storedCabinet, "name = ?", updateCabinetData.Name)
+		if storedCabinet.ID != uuid.Nil {
+			return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Cabinet with the same name already exist.", "data": nil})
+		}
+	}
+
+	// Find the cabinet with the given reader
+	if updateCabinetData.ReaderID != "" {
+		var storedCabinet model.Cabinet
+		db.Find(&storedCabinet, "reader_id = ? AND id <> ?", updateCabinetData.ReaderID, cabinet.ID)
+		// If the reader is already on another cabinet, return an error
+		if storedCabinet.ID != uuid.Nil {
+			return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Cabinet with the same reader already exist.", "data": nil})
+		}
+	}
+
+	// Edit the cabinet
+	cabinet.Name = updateCabinetData.Name
+	cabinet.Location = updateCabinetData.Location
+	cabinet.ReaderID = updateCabinetData.ReaderID
+	cabinet.ReturnAnywhere = updateCabinetData.ReturnAnywhere
+
+	// Save the Changes
+	db.Save(&cabinet)
+
+	// Keep the cabinet name of its keys up to date
+	db.Model(&model.Key{}).Where("cabinet_id = ?", cabinet.ID).Update("cabinet_name", cabinet.Name)
+
+	// Return the updated cabinet
+	return c.JSON(fiber.Map{"status": "success", "message": "Cabinet Updated", "data": cabinet})
+}
+
+// DeleteCabinet delete a cabinet by name
+// @Description Delete a Cabinet by name
+// @Tags Cabinet
+// @Accept json
+// @Produce json
+// @Success 200
+// @router /api/cabinet/{name} [delete]
+func DeleteCabinet(c *fiber.Ctx) error {
+	db := database.DB
+	var cabinet model.Cabinet
+
+	// Read the cabinet name param
+	cabinet_name := c.Params("name")
+
+	// Find the cabinet with the given name param
+	db.Find(&cabinet, "name = ?", cabinet_name)
+
+	// If no such cabinet present return an error
+	if cabinet.ID == uuid.Nil {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Cabinet not found", "data": nil})
+	}
+
+	// If keys still belong to or were last left in the cabinet, return an error
+	var keys int64
+	db.Model(&model.Key{}).Where("cabinet_id = ? OR current_cabinet_id = ?", cabinet.ID, cabinet.ID).Count(&keys)
+	if keys > 0 {
+		return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Cabinet still has keys.", "data": nil})
+	}
+
+	// Delete the cabinet
+	err := db.Delete(&cabinet, "name = ?", cabinet_name).Error
+
+	// Return error if encountered
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to delete cabinet", "data": nil})
+	}
+
+	// Return success message
+	return c.JSON(fiber.Map{"status": "success", "message": "Cabinet Deleted"})
+}
@@ -0,0 +1,191 @@
+package grantHandler
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/vincemoke66/keyper-api/database"
+	recordHandler "github.com/vincemoke66/keyper-api/internals/handlers/record"
+	"github.com/vincemoke66/keyper-api/internals/model"
+)
+
+// GetGrants func gets all room access grants
+// @Description Get all room access grants, optionally of one room or person and only those that have not expired
+// @Tags Grant
+// @Accept json
+// @Produce json
+// @Param room query string false "room"
+// @Param school_id query string false "school_id"
+// @Param active query bool false "active"
+// @Success 200 {array} model.RoomAccessGrant
+// @router /api/grant [get]
+func GetGrants(c *fiber.Ctx) error {
+	db := database.DB
+	var grants []model.RoomAccessGrant
+
+	query := db.Order("created_at DESC")
+	if room := c.Query("room"); room != "" {
+		query = query.Where("room_name = ?", room)
+	}
+	if school_id := c.Query("school_id"); school_id != "" {
+		query = query.Where("holder_school_id = ?", school_id)
+	}
+	if c.QueryBool("active") {
+		query = query.Where("expires_at IS NULL OR expires_at > ?", time.Now())
+	}
+
+	// find all grants in the database
+	query.Find(&grants)
+
+	// If no grant is present return an error
+	if len(grants) == 0 {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "No Grants data found", "data": nil})
+	}
+
+	// Return grants
+	return c.JSON(fiber.Map{"status": "success", "message": "Grants Found", "data": grants})
+}
+
+// CreateGrant func grants a student or instructor access to a restricted room
+// @Description Grants a student or instructor access to the keys of a room, until expires_at or until deleted. Grants are issued by an instructor, given by school id in granted_by, or by an admin, given by name.
+// @Tags Grant
+// @Accept json
+// @Produce json
+// @Param room_name body string true "room_name"
+// @Param holder_type body string false "holder_type"
+// @Param school_id body string true "school_id"
+// @Param expires_at body string false "expires_at"
+// @Param reason body string false "reason"
+// @Param granted_by_type body string true "granted_by_type"
+// @Param granted_by body string true "granted_by"
+// @Success 200 {object} model.RoomAccessGrant
+// @router /api/grant [post]
+func CreateGrant(c *fiber.Ctx) error {
+	db := database.DB
+	grant := new(model.RoomAccessGrant)
+
+	type GrantToAdd struct {
+		RoomName      string              `json:"room_name"`
+		HolderType    model.HolderType    `json:"holder_type"`
+		SchoolID      string              `json:"school_id"`
+		ExpiresAt     string              `json:"expires_at"`
+		Reason        string              `json:"reason"`
+		GrantedByType model.GrantedByType `json:"granted_by_type"`
+		GrantedBy     string              `json:"granted_by"`
+	}
+
+	grant_to_add := new(GrantToAdd)
+
+	// Parse the body to the grant object
+	err := c.BodyParser(grant_to_add)
+	// Return parse error if any
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Review your input", "data": err})
+	}
+	if grant_to_add.SchoolID == "" || grant_to_add.GrantedBy == "" {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Review your input", "data": nil})
+	}
+
+	// Only students and instructors are granted access
+	if grant_to_add.HolderType != "" && grant_to_add.HolderType != model.HolderTypeStudent && grant_to_add.HolderType != model.HolderTypeInstructor {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Invalid holder_type", "data": nil})
+	}
+
+	// Parse the expiry if one was given
+	if grant_to_add.ExpiresAt != "" {
+		expiresAt, err := time.Parse(time.RFC3339, grant_to_add.ExpiresAt)
+		if err != nil || !expiresAt.After(time.Now()) {
+			return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Invalid expires_at", "data": nil})
+		}
+		grant.ExpiresAt = &expiresAt
+	}
+
+	// Find who issues the grant, admins are only known by name
+	switch grant_to_add.GrantedByType {
+	case model.GrantedByInstructor:
+		issuer, err := recordHandler.FindBorrower(db, model.HolderTypeInstructor, grant_to_add.GrantedBy)
+		if err != nil {
+			return recordHandler.RespondErrorWith(c, err, "Could not create grant")
+		}
+		grant.GrantedByID = issuer.ID
+		grant.GrantedBy = issuer.Name
+	case model.GrantedByAdmin:
+		grant.GrantedBy = grant_to_add.GrantedBy
+	default:
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Invalid granted_by_type", "data": nil})
+	}
+
+	// Create a temporary room data
+	var storedRoom model.Room
+	db.Find(&storedRoom, "name = ?", grant_to_add.RoomName)
+	// If room does not exist, return an error
+	if storedRoom.ID == uuid.Nil {
+		return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Room does not exist.", "data": nil})
+	}
+
+	var storedBuilding model.Building
+	db.Find(&storedBuilding, "id = ?", storedRoom.BuildingID)
+
+	// Find the person given access
+	holder, err := recordHandler.FindBorrower(db, grant_to_add.HolderType, grant_to_add.SchoolID)
+	if err != nil {
+		return recordHandler.RespondErrorWith(c, err, "Could not create grant")
+	}
+
+	// Add a uuid to the new grant
+	grant.ID = uuid.New()
+
+	grant.RoomID = storedRoom.ID
+	grant.RoomName = storedRoom.Name
+	grant.BuildingName = storedBuilding.Name
+	grant.HolderType = holder.Type
+	grant.HolderID = holder.ID
+	grant.HolderSchoolID = holder.SchoolID
+	grant.HolderName = holder.Name
+	grant.Reason = grant_to_add.Reason
+	grant.GrantedByType = grant_to_add.GrantedByType
+
+	// Create the Grant and return error if encountered
+	err = db.Create(&grant).Error
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Could not create grant", "data": err})
+	}
+
+	// Return the created grant
+	return c.JSON(fiber.Map{"status": "success", "message": "Grant created", "data": grant})
+}
+
+// DeleteGrant revoke a room access grant by id
+// @Description Revoke a room access grant by id
+// @Tags Grant
+// @Accept json
+// @Produce json
+// @Success 200
+// @router /api/grant/{id} [delete]
+func DeleteGrant(c *fiber.Ctx) error {
+	db := database.DB
+	var grant model.RoomAccessGrant
+
+	// Read the param id
+	id := c.Params("id")
+
+	// Find the grant with the given id param
+	db.Find(&grant, "id = ?", id)
+
+	// If no such grant present return an error
+	if grant.ID == uuid.Nil {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Grant not found", "data": nil})
+	}
+
+	// Delete the grant
+	err := db.Delete(&grant, "id = ?", id).Error
+
+	// Return error if encountered
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to delete grant", "data": nil})
+	}
+
+	// Return success message
+	return c.JSON(fiber.Map{"status": "success", "message": "Grant Deleted"})
+}
@@ -0,0 +1,204 @@
+package guestHandler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/vincemoke66/keyper-api/database"
+	"github.com/vincemoke66/keyper-api/internals/model"
+)
+
+// GetGuests func gets all existing guests
+// @Description Get all existing guests
+// @Tags Guest
+// @Accept json
+// @Produce json
+// @Success 200 {array} model.Guest
+// @router /api/guest [get]
+func GetGuests(c *fiber.Ctx) error {
+	db := database.DB
+	var guests []model.Guest
+
+	// find all guests in the database
+	db.Find(&guests)
+
+	// If no guest is present return an error
+	if len(guests) == 0 {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "No Guests data found", "data": nil})
+	}
+
+	// Return guests
+	return c.JSON(fiber.Map{"status": "success", "message": "Guests Found", "data": guests})
+}
+
+// CreateGuest func create a guest
+// @Description Create a Guest
+// @Tags Guest
+// @Accept json
+// @Produce json
+// @Param first_name body string true "first_name"
+// @Param last_name body string true "last_name"
+// @Param pass_number body string true "pass_number"
+// @Param rfid body string true "rfid"
+// @Param affiliation body string true "affiliation"
+// @Success 200 {object} model.Guest
+// @router /api/guest [post]
+func CreateGuest(c *fiber.Ctx) error {
+	db := database.DB
+	guest := new(model.Guest)
+
+	// Parse the body to the guest object
+	err := c.BodyParser(guest)
+	// Return parse error if any
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Review your input", "data": err})
+	}
+	// Return invalid pass_number if empty or null
+	if guest.PassNumber == uuid.Nil.String() || guest.PassNumber == "" {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Invalid Pass Number", "data": err})
+	}
+
+	// Create a temporary guest data
+	var storedGuest model.Guest
+
+	// Find the guest with the given pass_number
+	db.Find(&storedGuest, "pass_number = ?", guest.PassNumber)
+
+	// If guest pass number exists, return an error
+	if storedGuest.ID != uuid.Nil {
+		return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Guest with the same pass number already exist.", "data": nil})
+	}
+
+	// Find the guest with the given rfid
+	if guest.RFID != "" {
+		db.Find(&storedGuest, "rfid = ?", guest.RFID)
+		// If guest rfid exists, return an error
+		if storedGuest.ID != uuid.Nil {
+			return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Guest with the same rfid already exist.", "data": nil})
+		}
+	}
+
+	// Add a uuid to the new guest
+	guest.ID = uuid.New()
+
+	// Create the Guest and return error if encountered
+	err = db.Create(&guest).Error
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Could not create guest", "data": err})
+	}
+
+	// Return the created guest
+	return c.JSON(fiber.Map{"status": "success", "message": "Guest created", "data": guest})
+}
+
+// GetGuest func get one guest by pass_number
+// @Description Get one guest by pass_number
+// @Tags Guest
+// @Accept json
+// @Produce json
+// @Success 200 {object} model.Guest
+// @router /api/guest/{pass_number} [get]
+func GetGuest(c *fiber.Ctx) error {
+	db := database.DB
+	var guest model.Guest
+
+	// Read the param pass_number
+	pass_number := c.Params("pass_number")
+
+	// Find the guest with the given pass_number
+	db.Find(&guest, "pass_number = ?", pass_number)
+
+	// If no such guest present, return an error
+	if guest.ID == uuid.Nil {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Guest not found", "data": nil})
+	}
+
+	// Return the guest with the specified pass_number
+	return c.JSON(fiber.Map{"status": "success", "message": "Guest Found", "data": guest})
+}
+
+// UpdateGuest update a guest by pass_number
+// @Description Update a Guest by pass_number
+// @Tags Guest
+// @Accept json
+// @Produce json
+// @Param first_name body string true "first_name"
+// @Param last_name body string true "last_name"
+// @Param affiliation body string true "affiliation"
+// @Success 200 {object} model.Guest
+// @router /api/guest/{pass_number} [put]
+func UpdateGuest(c *fiber.Ctx) error {
+	// Create a struct for updating only writable values
+	type updateGuest struct {
+		FirstName   string `json:"first_name"`
+		LastName    string `json:"last_name"`
+		Affiliation string `json:"affiliation"`
+	}
+
+	db := database.DB
+	var guest model.Guest
+
+	// Read the param pass_number
+	pass_number := c.Params("pass_number")
+
+	// Find the guest with the given pass_number param
+	db.Find(&guest, "pass_number = ?", pass_number)
+
+	// If no such guest, return an error
+	if guest.ID == uuid.Nil {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Guest not found", "data": nil})
+	}
+
+	// Store the body containing the updated data
+	var updateGuestData updateGuest
+	err := c.BodyParser(&updateGuestData)
+
+	// Return parsing error if encountered
+	if err != nil {
+		return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Review your input", "data": err})
+	}
+
+	// Edit the guest
+	guest.FirstName = updateGuestData.FirstName
+	guest.LastName = updateGuestData.LastName
+	guest.Affiliation = updateGuestData.Affiliation
+
+	// Save the Changes
+	db.Save(&guest)
+
+	// Return the updated guest
+	return c.JSON(fiber.Map{"status": "success", "message": "Guest Updated", "data": guest})
+}
+
+// DeleteGuest delete a guest by pass_number
+// @Description Delete a Guest by pass_number
+// @Tags Guest
+// @Accept json
+// @Produce json
+// @Success 200
+// @router /api/guest/{pass_number} [delete]
+func DeleteGuest(c *fiber.Ctx) error {
+	db := database.DB
+	var guest model.Guest
+
+	// Read the param pass_number
+	pass_number := c.Params("pass_number")
+
+	// Find the guest with the given pass_number param
+	db.Find(&guest, "pass_number = ?", pass_number)
+
+	// If no such guest present return an error
+	if guest.ID == uuid.Nil {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Guest not found", "data": nil})
+	}
+
+	// Delete the guest
+	err := db.Delete(&guest, "pass_number = ?", pass_number).Error
+
+	// Return error if encountered
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to delete guest", "data": nil})
+	}
+
+	// Return success message
+	return c.JSON(fiber.Map{"status": "success", "message": "Guest Deleted"})
+}
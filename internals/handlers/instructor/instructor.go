@@ -38,6 +38,8 @@ func GetInstructors(c *fiber.Ctx) error {
 // @Param first_name body string true "first_name"
 // @Param last_name body string true "last_name"
 // @Param school_id body string true "school_id"
+// @Param rfid body string false "rfid"
+// @Param master_key_access body bool false "master_key_access"
 // @Success 200 {object} model.Instructor
 // @router /api/instructor [post]
 func CreateInstructor(c *fiber.Ctx) error {
@@ -66,6 +68,15 @@ func CreateInstructor(c *fiber.Ctx) error {
 		return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Instructor with the same school id already exist.", "data": nil})
 	}
 
+	// Find the instructor with the given rfid
+	if instructor.RFID != "" {
+		db.Find(&storedInstructor, "rfid = ?", instructor.RFID)
+		// If instructor rfid exists, return an error
+		if storedInstructor.ID != uuid.Nil {
+			return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Instructor with the same rfid already exist.", "data": nil})
+		}
+	}
+
 	// Add a uuid to the new instructor
 	instructor.ID = uuid.New()
 
@@ -112,6 +123,7 @@ func GetInstructor(c *fiber.Ctx) error {
 // @Produce json
 // @Param first_name body string true "first_name"
 // @Param last_name body string true "last_name"
+// @Param master_key_access body bool false "master_key_access"
 // @Success 200 {object} model.Instructor
 // @router /api/instructor/{school_id} [put]
 func UpdateInstructor(c *fiber.Ctx) error {
@@ -119,6 +131,8 @@ func UpdateInstructor(c *fiber.Ctx) error {
 	type updateInstructor struct {
 		FirstName string `json:"first_name"`
 		LastName  string `json:"last_name"`
+		// MasterKeyAccess lets the instructor borrow master keys
+		MasterKeyAccess bool `json:"master_key_access"`
 	}
 
 	db := database.DB
@@ -147,6 +161,7 @@ func UpdateInstructor(c *fiber.Ctx) error {
 	// Edit the instructor
 	instructor.FirstName = updateStudentData.FirstName
 	instructor.LastName = updateStudentData.LastName
+	instructor.MasterKeyAccess = updateStudentData.MasterKeyAccess
 
 	// Save the Changes
 	db.Save(&instructor)
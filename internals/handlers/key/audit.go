@@ -0,0 +1,339 @@
+package keyHandler
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/vincemoke66/keyper-api/database"
+	recordHandler "github.com/vincemoke66/keyper-api/internals/handlers/record"
+	"github.com/vincemoke66/keyper-api/internals/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetKeyAudits func gets all key audits
+// @Description Get all key audits, newest first, optionally of one building
+// @Tags Key
+// @Accept json
+// @Produce json
+// @Param building query string false "building"
+// @Success 200 {array} model.KeyAudit
+// @router /api/key/audit [get]
+func GetKeyAudits(c *fiber.Ctx) error {
+	db := database.DB
+	var audits []model.KeyAudit
+
+	query := db.Order("created_at DESC")
+	if building := c.Query("building"); building != "" {
+		query = query.Where("building_name = ?", building)
+	}
+
+	// find all audits in the database
+	query.Find(&audits)
+
+	// If no audit is present return an error
+	if len(audits) == 0 {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "No Audits data found", "data": nil})
+	}
+
+	// Return audits
+	return c.JSON(fiber.Map{"status": "success", "message": "Audits Found", "data": audits})
+}
+
+// GetKeyAudit func get one key audit by id
+// @Description Get one key audit by id along with its findings
+// @Tags Key
+// @Accept json
+// @Produce json
+// @Success 200 {object} model.KeyAudit
+// @router /api/key/audit/{id} [get]
+func GetKeyAudit(c *fiber.Ctx) error {
+	db := database.DB
+	var audit model.KeyAudit
+
+	// Read the param id
+	id := c.Params("id")
+
+	// Find the audit with the given id
+	db.Find(&audit, "id = ?", id)
+
+	// If no such audit present, return an error
+	if audit.ID == uuid.Nil {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Audit not found", "data": nil})
+	}
+
+	// Find the findings of the audit
+	var findings []model.KeyAuditFinding
+	db.Order("kind, room_name, rfid").Find(&findings, "audit_id = ?", audit.ID)
+
+	// Return the audit with the specified id
+	return c.JSON(fiber.Map{"status": "success", "message": "Audit Found", "data": fiber.Map{"audit": audit, "findings": findings}})
+}
+
+// CreateKeyAudit func compares the tags found in a building's key box with the key statuses
+// @Description Stores an audit of the scanned rfids of a building, reporting available keys that are missing, borrowed or lost keys that are present and unknown tags
+// @Tags Key
+// @Accept json
+// @Produce json
+// @Param building_name body string true "building_name"
+// @Param rfids body []string true "rfids"
+// @Param audited_by body string true "audited_by"
+// @Param note body string false "note"
+// @Success 200 {object} model.KeyAudit
+// @router /api/key/audit [post]
+func CreateKeyAudit(c *fiber.Ctx) error {
+	db := database.DB
+	audit := new(model.KeyAudit)
+
+	type AuditToAdd struct {
+		BuildingName string   `json:"building_name"`
+		RFIDs        []string `json:"rfids"`
+		AuditedBy    string   `json:"audited_by"`
+		Note         string   `json:"note"`
+	}
+
+	audit_to_add := new(AuditToAdd)
+
+	// Parse the body to the audit object
+	err := c.BodyParser(audit_to_add)
+	// Return parse error if any
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Review your input", "data": err})
+	}
+	if audit_to_add.BuildingName == "" || audit_to_add.AuditedBy == "" {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Review your input", "data": nil})
+	}
+
+	// Create a temporary building data
+	var storedBuilding model.Building
+	db.Find(&storedBuilding, "name = ?", audit_to_add.BuildingName)
+	// If building does not exist, return an error
+	if storedBuilding.ID == uuid.Nil {
+		return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Building does not exist.", "data": nil})
+	}
+
+	// A tag read twice by the scanner counts once
+	scanned := map[string]bool{}
+	for _, rfid := range audit_to_add.RFIDs {
+		if rfid != "" {
+			scanned[rfid] = true
+		}
+	}
+
+	audit.ID = uuid.New()
+	audit.BuildingID = storedBuilding.ID
+	audit.BuildingName = storedBuilding.Name
+	audit.AuditedBy = audit_to_add.AuditedBy
+	audit.Note = audit_to_add.Note
+	audit.Scanned = len(scanned)
+
+	var findings []model.KeyAuditFinding
+	addFinding := func(kind model.AuditFindingKind, key model.Key, rfid string, note string) {
+		findings = append(findings, model.KeyAuditFinding{
+			ID:         uuid.New(),
+			AuditID:    audit.ID,
+			Kind:       kind,
+			KeyID:      key.ID,
+			RFID:       rfid,
+			RoomName:   key.RoomName,
+			KeyStatus:  key.Status,
+			HolderName: key.HolderName,
+			Note:       note,
+		})
+	}
+
+	// Compare every key of the building with what was found in the box
+	var keys []model.Key
+	db.Order("room_name, copy_number").Find(&keys, "building_id = ?", storedBuilding.ID)
+	for _, key := range keys {
+		present := scanned[key.RFID]
+		delete(scanned, key.RFID)
+
+		switch {
+		case key.Status == model.KeyStatusAvailable && present:
+			audit.Matched++
+		case key.Status == model.KeyStatusAvailable:
+			audit.Missing++
+			addFinding(model.AuditFindingMissing, key, key.RFID, "")
+		case key.Status == model.KeyStatusBorrowed && present:
+			audit.PresentBorrowed++
+			addFinding(model.AuditFindingPresentBorrowed, key, key.RFID, "")
+		case key.Status == model.KeyStatusLost && present:
+			audit.PresentLost++
+			addFinding(model.AuditFindingPresentLost, key, key.RFID, "")
+		case present:
+			// Keys in maintenance or out of use may hang in the box
+			audit.Matched++
+		}
+	}
+
+	// Whatever is left is not a key of the building
+	for rfid := range scanned {
+		var otherKey model.Key
+		db.Find(&otherKey, "rfid = ?", rfid)
+
+		note := "Tag is not registered"
+		if otherKey.ID != uuid.Nil {
+			note = "Key of " + otherKey.RoomName + " in " + otherKey.BuildingName
+		}
+		audit.Unknown++
+		addFinding(model.AuditFindingUnknown, model.Key{ID: otherKey.ID, RoomName: otherKey.RoomName, Status: otherKey.Status}, rfid, note)
+	}
+
+	// Store the audit along with its findings
+	err = db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(audit).Error
+		if err != nil {
+			return err
+		}
+		if len(findings) == 0 {
+			return nil
+		}
+		return tx.Create(&findings).Error
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Could not create audit", "data": err})
+	}
+
+	if findings == nil {
+		findings = []model.KeyAuditFinding{}
+	}
+
+	// Return the created audit
+	return c.JSON(fiber.Map{"status": "success", "message": "Audit created", "data": fiber.Map{"audit": audit, "findings": findings}})
+}
+
+// ApplyKeyAudit func corrects the key statuses to what an audit found
+// @Description Corrects the findings of an audit, all of them when finding_ids is empty. Missing keys are reported lost, borrowed keys in the box are returned by their holder and lost keys in the box are marked found. Keys whose status changed since the audit are skipped.
+// @Tags Key
+// @Accept json
+// @Produce json
+// @Param finding_ids body []string false "finding_ids"
+// @Param applied_by body string true "applied_by"
+// @Success 200 {array} model.KeyAuditFinding
+// @router /api/key/audit/{id}/apply [post]
+func ApplyKeyAudit(c *fiber.Ctx) error {
+	db := database.DB
+
+	type Corrections struct {
+		FindingIDs []string `json:"finding_ids"`
+		AppliedBy  string   `json:"applied_by"`
+	}
+
+	corrections := new(Corrections)
+
+	// Parse the body to the corrections object
+	err := c.BodyParser(corrections)
+	// Return parse error if any
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Review your input", "data": err})
+	}
+	if corrections.AppliedBy == "" {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Review your input", "data": nil})
+	}
+
+	// Read the param id
+	id := c.Params("id")
+
+	var findings []model.KeyAuditFinding
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Find and lock the audit so that it is applied once at a time
+		var audit model.KeyAudit
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Find(&audit, "id = ?", id).Error
+		if err != nil {
+			return err
+		}
+		if audit.ID == uuid.Nil {
+			return &recordHandler.RecordError{Status: 404, Message: "Audit not found"}
+		}
+
+		// Only the findings not resolved yet are corrected
+		query := tx.Order("room_name, rfid").Where("audit_id = ? AND (resolution IS NULL OR resolution = '')", audit.ID)
+		if len(corrections.FindingIDs) > 0 {
+			query = query.Where("id IN ?", corrections.FindingIDs)
+		}
+		query.Find(&findings)
+		if len(findings) == 0 {
+			return &recordHandler.RecordError{Status: 404, Message: "No findings left to correct"}
+		}
+
+		note := "Audit of " + audit.BuildingName + " on " + audit.CreatedAt.Local().Format("2006-01-02") + " by " + audit.AuditedBy
+		now := time.Now()
+		for i := range findings {
+			err := correctFinding(tx, &findings[i], note)
+			if err != nil {
+				return err
+			}
+			findings[i].ResolvedAt = &now
+			err = tx.Save(&findings[i]).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		audit.AppliedAt = &now
+		audit.AppliedBy = corrections.AppliedBy
+		return tx.Save(&audit).Error
+	})
+	if err != nil {
+		return recordHandler.RespondErrorWith(c, err, "Could not apply audit")
+	}
+
+	// Return the corrected findings
+	return c.JSON(fiber.Map{"status": "success", "message": "Audit applied", "data": findings})
+}
+
+// correctFinding brings the key of the finding in line with the audit,
+// skipping it when the key changed since
+func correctFinding(tx *gorm.DB, finding *model.KeyAuditFinding, note string) error {
+	skip := func(reason string) error {
+		finding.Resolution = model.AuditResolutionSkipped
+		finding.Note = reason
+		return nil
+	}
+
+	if finding.Kind == model.AuditFindingUnknown {
+		return skip("Unknown tags cannot be corrected")
+	}
+
+	key, err := lockKey(tx, finding.RFID)
+	if _, ok := err.(*recordHandler.RecordError); ok {
+		return skip("Key no longer exists")
+	}
+	if err != nil {
+		return err
+	}
+	if key.Status != finding.KeyStatus {
+		return skip("Key is now " + string(key.Status))
+	}
+
+	var record model.Record
+	switch finding.Kind {
+	case model.AuditFindingMissing:
+		record, err = TransitionKey(tx, &key, model.KeyStatusLost, model.RecordTypeLost, "Missing in "+note)
+	case model.AuditFindingPresentBorrowed:
+		record, err = recordHandler.ProcessRecord(tx, recordHandler.RecordToAdd{Type: model.RecordTypeReturn, RFID: key.RFID, Note: "Found in the box in " + note, Correction: true})
+	case model.AuditFindingPresentLost:
+		record, err = TransitionKey(tx, &key, model.KeyStatusAvailable, model.RecordTypeFound, "Found in the box in "+note)
+	}
+	if recordErr, ok := err.(*recordHandler.RecordError); ok {
+		return skip(recordErr.Message)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Lost and found keys are marked as corrections of the audit as well
+	if !record.Correction {
+		record.Correction = true
+		err = tx.Model(&model.Record{}).Where("id = ?", record.ID).Update("correction", true).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	finding.Resolution = model.AuditResolutionCorrected
+	finding.RecordID = record.ID
+	return nil
+}
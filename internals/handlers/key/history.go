@@ -0,0 +1,241 @@
+package keyHandler
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/vincemoke66/keyper-api/database"
+	"github.com/vincemoke66/keyper-api/internals/model"
+	"gorm.io/gorm"
+)
+
+// CustodyPeriod is one stretch of time a borrower held a key
+type CustodyPeriod struct {
+	KeyRFID          string           `json:"key_rfid"`
+	BorrowerType     model.HolderType `json:"borrower_type"`
+	BorrowerSchoolID string           `json:"borrower_school_id"`
+	BorrowerName     string           `json:"borrower_name"`
+	BorrowedAt       time.Time        `json:"borrowed_at"`
+	DueAt            *time.Time       `json:"due_at"`
+	EndedAt          *time.Time       `json:"ended_at"`
+	// TransferredFrom is the previous holder when the key was handed over
+	TransferredFrom string `json:"transferred_from,omitempty"`
+	// EndedBy is the type of the record that ended the custody, e.g. return, transfer or lost
+	EndedBy         model.RecordType `json:"ended_by"`
+	DurationMinutes int64            `json:"duration_minutes"`
+	Duration        string           `json:"duration"`
+	Late            bool             `json:"late"`
+}
+
+// KeyHistory is the chain of custody of a key and the keys it replaced
+type KeyHistory struct {
+	Key                    model.Key       `json:"key"`
+	KeyChain               []string        `json:"key_chain"`
+	CurrentHolder          *CustodyPeriod  `json:"current_holder"`
+	TotalBorrows           int             `json:"total_borrows"`
+	TotalTransfers         int             `json:"total_transfers"`
+	TotalDurationMinutes   int64           `json:"total_duration_minutes"`
+	AverageDurationMinutes int64           `json:"average_duration_minutes"`
+	LateReturns            int             `json:"late_returns"`
+	Custody                []CustodyPeriod `json:"custody"`
+	Events                 []model.Record  `json:"events"`
+}
+
+// GetKeyHistory func gets the chain of custody of a key
+// @Description Gets every borrow of a key paired with its return, with totals and the current holder
+// @Tags Key
+// @Accept json
+// @Produce json
+// @Success 200 {object} KeyHistory
+// @router /api/key/rfid/{rfid}/history [get]
+func GetKeyHistory(c *fiber.Ctx) error {
+	db := database.DB
+	var key model.Key
+
+	// Read the param rfid
+	rfid := c.Params("rfid")
+
+	// Find the key with the given rfid
+	db.Find(&key, "rfid = ?", rfid)
+	// If key does not exist, return an error
+	if key.ID == uuid.Nil {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Key not found", "data": nil})
+	}
+
+	// Return the history of the key
+	return c.JSON(fiber.Map{"status": "success", "message": "Key History Found", "data": buildKeyHistory(db, key)})
+}
+
+// GetKeyHolder func gets who currently holds a key
+// @Description Gets who currently holds a key and since when
+// @Tags Key
+// @Accept json
+// @Produce json
+// @Success 200 {object} KeyHolder
+// @router /api/key/rfid/{rfid}/holder [get]
+func GetKeyHolder(c *fiber.Ctx) error {
+	db := database.DB
+	var key model.Key
+
+	// Read the param rfid
+	rfid := c.Params("rfid")
+
+	// Find the key with the given rfid
+	db.Find(&key, "rfid = ?", rfid)
+	// If key does not exist, return an error
+	if key.ID == uuid.Nil {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Key not found", "data": nil})
+	}
+
+	// If nobody holds the key, return its status
+	if key.Status != model.KeyStatusBorrowed || key.BorrowedAt == nil {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Key is " + string(key.Status), "data": nil})
+	}
+
+	heldFor := time.Since(*key.BorrowedAt)
+	holder := KeyHolder{
+		RFID:           key.RFID,
+		RoomName:       key.RoomName,
+		BuildingName:   key.BuildingName,
+		HolderType:     key.HolderType,
+		HolderSchoolID: key.HolderSchoolID,
+		HolderName:     key.HolderName,
+		BorrowedAt:     *key.BorrowedAt,
+		DueAt:          key.DueAt,
+		Overdue:        key.Overdue || (key.DueAt != nil && time.Now().After(*key.DueAt)),
+		HeldMinutes:    int64(heldFor.Minutes()),
+		HeldFor:        heldFor.Round(time.Minute).String(),
+	}
+
+	// Return the holder of the key
+	return c.JSON(fiber.Map{"status": "success", "message": "Key Holder Found", "data": holder})
+}
+
+// KeyHolder is the person currently holding a borrowed key
+type KeyHolder struct {
+	RFID           string           `json:"rfid"`
+	RoomName       string           `json:"room_name"`
+	BuildingName   string           `json:"building_name"`
+	HolderType     model.HolderType `json:"holder_type"`
+	HolderSchoolID string           `json:"holder_school_id"`
+	HolderName     string           `json:"holder_name"`
+	BorrowedAt     time.Time        `json:"borrowed_at"`
+	DueAt          *time.Time       `json:"due_at"`
+	Overdue        bool             `json:"overdue"`
+	HeldMinutes    int64            `json:"held_minutes"`
+	HeldFor        string           `json:"held_for"`
+}
+
+// buildKeyHistory pairs the records of the key, and of the keys it replaced,
+// into custody periods
+func buildKeyHistory(db *gorm.DB, key model.Key) KeyHistory {
+	history := KeyHistory{Key: key, Custody: []CustodyPeriod{}, Events: []model.Record{}}
+
+	// Follow the replacements back to the first key
+	rfids := map[uuid.UUID]string{key.ID: key.RFID}
+	keyIDs := []uuid.UUID{key.ID}
+	history.KeyChain = []string{key.RFID}
+	for replaced := key.ReplacesKeyID; replaced != uuid.Nil; {
+		var previous model.Key
+		db.Unscoped().Find(&previous, "id = ?", replaced)
+		if previous.ID == uuid.Nil || rfids[previous.ID] != "" {
+			break
+		}
+		rfids[previous.ID] = previous.RFID
+		keyIDs = append(keyIDs, previous.ID)
+		history.KeyChain = append([]string{previous.RFID}, history.KeyChain...)
+		replaced = previous.ReplacesKeyID
+	}
+
+	var records []model.Record
+	db.Order("created_at ASC").Find(&records, "key_id IN ?", keyIDs)
+
+	now := time.Now()
+	var open *CustodyPeriod
+	closePeriod := func(record model.Record) {
+		endedAt := record.CreatedAt
+		open.EndedAt = &endedAt
+		open.EndedBy = record.Type
+		open.Late = !record.Correction && open.DueAt != nil && endedAt.After(*open.DueAt)
+		setDuration(open, endedAt)
+		history.Custody = append(history.Custody, *open)
+		open = nil
+	}
+
+	for _, record := range records {
+		switch record.Type {
+		case model.RecordTypeBorrow:
+			// A borrow without a return before it ends the previous custody
+			if open != nil {
+				closePeriod(record)
+			}
+			open = &CustodyPeriod{
+				KeyRFID:          rfids[record.KeyID],
+				BorrowerType:     record.BorrowerType,
+				BorrowerSchoolID: record.BorrowerSchoolID,
+				BorrowerName:     record.BorrowerName,
+				BorrowedAt:       record.CreatedAt,
+				DueAt:            record.DueAt,
+			}
+		case model.RecordTypeTransfer:
+			// The custody moves straight to the new holder
+			if open != nil {
+				closePeriod(record)
+			}
+			open = &CustodyPeriod{
+				KeyRFID:          rfids[record.KeyID],
+				BorrowerType:     record.BorrowerType,
+				BorrowerSchoolID: record.BorrowerSchoolID,
+				BorrowerName:     record.BorrowerName,
+				TransferredFrom:  record.FromHolderName,
+				BorrowedAt:       record.CreatedAt,
+				DueAt:            record.DueAt,
+			}
+		case model.RecordTypeReturn:
+			if open != nil {
+				closePeriod(record)
+			}
+		default:
+			// Lifecycle records, a key lost while borrowed ends the custody
+			if open != nil && record.Type == model.RecordTypeLost {
+				closePeriod(record)
+			}
+			history.Events = append(history.Events, record)
+		}
+	}
+
+	// The custody still open belongs to the current holder
+	if open != nil {
+		open.Late = open.DueAt != nil && now.After(*open.DueAt)
+		setDuration(open, now)
+		history.Custody = append(history.Custody, *open)
+		history.CurrentHolder = open
+	}
+
+	// Add up the totals
+	for _, period := range history.Custody {
+		if period.TransferredFrom != "" {
+			history.TotalTransfers++
+		} else {
+			history.TotalBorrows++
+		}
+		history.TotalDurationMinutes += period.DurationMinutes
+		// Only keys brought back late count, not those handed over or lost
+		if period.Late && period.EndedBy == model.RecordTypeReturn {
+			history.LateReturns++
+		}
+	}
+	if len(history.Custody) > 0 {
+		history.AverageDurationMinutes = history.TotalDurationMinutes / int64(len(history.Custody))
+	}
+
+	return history
+}
+
+// setDuration sets how long the custody lasted until the given time
+func setDuration(period *CustodyPeriod, until time.Time) {
+	duration := until.Sub(period.BorrowedAt)
+	period.DurationMinutes = int64(duration.Minutes())
+	period.Duration = duration.Round(time.Minute).String()
+}
@@ -1,10 +1,14 @@
 package keyHandler
 
 import (
+	"time"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"github.com/vincemoke66/keyper-api/database"
+	recordHandler "github.com/vincemoke66/keyper-api/internals/handlers/record"
 	"github.com/vincemoke66/keyper-api/internals/model"
+	"gorm.io/gorm"
 )
 
 // GetKeys func gets all existing keys
@@ -90,6 +94,61 @@ func GetKeyUsingRFID(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"status": "success", "message": "Key Found", "data": key})
 }
 
+// GetOverdueKeys func gets all borrowed keys past their due time
+// @Description Gets all borrowed keys past their due time with their holder
+// @Tags Key
+// @Accept json
+// @Produce json
+// @Success 200 {array} OverdueKey
+// @router /api/key/overdue [get]
+func GetOverdueKeys(c *fiber.Ctx) error {
+	db := database.DB
+	var keys []model.Key
+
+	// find all borrowed keys flagged by the sweeper or already past due
+	now := time.Now()
+	db.Order("due_at ASC").Find(&keys, "status = ? AND due_at IS NOT NULL AND (overdue = ? OR due_at < ?)", model.KeyStatusBorrowed, true, now)
+
+	// If no key is overdue return an error
+	if len(keys) == 0 {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "No overdue keys found", "data": nil})
+	}
+
+	overdueKeys := make([]OverdueKey, 0, len(keys))
+	for _, key := range keys {
+		overdueFor := now.Sub(*key.DueAt)
+		overdueKeys = append(overdueKeys, OverdueKey{
+			RFID:             key.RFID,
+			RoomName:         key.RoomName,
+			BuildingName:     key.BuildingName,
+			BorrowerType:     key.HolderType,
+			BorrowerSchoolID: key.HolderSchoolID,
+			BorrowerName:     key.HolderName,
+			BorrowedAt:       key.BorrowedAt,
+			DueAt:            *key.DueAt,
+			OverdueMinutes:   int64(overdueFor.Minutes()),
+			OverdueFor:       overdueFor.Round(time.Minute).String(),
+		})
+	}
+
+	// Else return overdue keys
+	return c.JSON(fiber.Map{"status": "success", "message": "Overdue Keys Found", "data": overdueKeys})
+}
+
+// OverdueKey is a borrowed key past its due time along with its holder
+type OverdueKey struct {
+	RFID             string           `json:"rfid"`
+	RoomName         string           `json:"room_name"`
+	BuildingName     string           `json:"building_name"`
+	BorrowerType     model.HolderType `json:"borrower_type"`
+	BorrowerSchoolID string           `json:"borrower_school_id"`
+	BorrowerName     string           `json:"borrower_name"`
+	BorrowedAt       *time.Time       `json:"borrowed_at"`
+	DueAt            time.Time        `json:"due_at"`
+	OverdueMinutes   int64            `json:"overdue_minutes"`
+	OverdueFor       string           `json:"overdue_for"`
+}
+
 // CreateKey func creates a key
 // @Description Creates a Key
 // @Tags Key
@@ -99,6 +158,8 @@ func GetKeyUsingRFID(c *fiber.Ctx) error {
 // @Param status body string true "status"
 // @Param building_name body string true "building_name"
 // @Param room_name body string true "room_name"
+// @Param cabinet_name body string false "cabinet_name"
+// @Param access_group body string false "access_group"
 // @Success 200 {object} model.Key
 // @router /api/key [post]
 func CreateKey(c *fiber.Ctx) error {
@@ -110,6 +171,8 @@ func CreateKey(c *fiber.Ctx) error {
 		Status       model.KeyStatus `json:"status"`
 		BuildingName string          `json:"building_name"`
 		RoomName     string          `json:"room_name"`
+		CabinetName  string          `json:"cabinet_name"`
+		AccessGroup  string          `json:"access_group"`
 	}
 
 	key_to_add := new(KeyToAdd)
@@ -125,6 +188,18 @@ func CreateKey(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Invalid input", "data": err})
 	}
 
+	// Create a temporary access group data
+	var storedGroup model.AccessGroup
+	if key_to_add.AccessGroup != "" {
+		db.Find(&storedGroup, "name = ?", key_to_add.AccessGroup)
+		// If access group name does not exists, return an error
+		if storedGroup.ID == uuid.Nil {
+			return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Access group does not exist.", "data": nil})
+		}
+		// Master keys belong to the building of their group
+		key_to_add.BuildingName = storedGroup.BuildingName
+	}
+
 	// Create a temporary building data
 	var storedBuidling model.Building
 	db.Find(&storedBuidling, "name = ?", key_to_add.BuildingName)
@@ -133,18 +208,24 @@ func CreateKey(c *fiber.Ctx) error {
 		return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Building does not exist.", "data": nil})
 	}
 
-	// Create a temporary room data
+	// Create a temporary room data, master keys open the rooms of their group instead
 	var storedRoom model.Room
-	db.Find(&storedRoom, "name = ?", key_to_add.RoomName)
-	// If room name does not exists, return an error
-	if storedRoom.ID == uuid.Nil {
-		return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Room does not exist.", "data": nil})
+	if storedGroup.ID == uuid.Nil {
+		db.Find(&storedRoom, "name = ?", key_to_add.RoomName)
+		// If room name does not exists, return an error
+		if storedRoom.ID == uuid.Nil {
+			return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Room does not exist.", "data": nil})
+		}
 	}
-	var keys []model.Key
-	// Check if room id already exist in keys table
-	db.Find(&keys, "room_id = ?", storedRoom.ID)
-	if len(keys) != 0 {
-		return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Room already has a key.", "data": nil})
+
+	// Create a temporary cabinet data
+	var storedCabinet model.Cabinet
+	if key_to_add.CabinetName != "" {
+		db.Find(&storedCabinet, "name = ?", key_to_add.CabinetName)
+		// If cabinet name does not exists, return an error
+		if storedCabinet.ID == uuid.Nil {
+			return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Cabinet does not exist.", "data": nil})
+		}
 	}
 
 	// Create a temporary key data
@@ -163,14 +244,37 @@ func CreateKey(c *fiber.Ctx) error {
 
 	key.RFID = key_to_add.RFID
 	key.Status = key_to_add.Status
+	// New keys start available unless they are still being prepared
+	if key.Status == "" {
+		key.Status = model.KeyStatusAvailable
+	}
+	if key.Status != model.KeyStatusAvailable && key.Status != model.KeyStatusMaintenance && key.Status != model.KeyStatusUnavailable {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Invalid status", "data": nil})
+	}
 	key.BuildingID = storedBuidling.ID
 	key.RoomID = storedRoom.ID
 	key.BuildingName = storedBuidling.Name
 	key.RoomName = storedRoom.Name
 	key.RoomFloor = storedRoom.Floor
+	key.CabinetID = storedCabinet.ID
+	key.CabinetName = storedCabinet.Name
+	key.CurrentCabinetID = storedCabinet.ID
+	if storedGroup.ID != uuid.Nil {
+		key.AccessGroupID = storedGroup.ID
+		key.RoomName = storedGroup.Name
+	}
 
-	// Create the Key and return error if encountered
-	err = db.Create(&key).Error
+	// Add the key as the next copy of the room's key set and return error if encountered
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Master keys are not part of any room's key set
+		if key.AccessGroupID == uuid.Nil {
+			err := assignKeySet(tx, key, storedRoom, storedBuidling)
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Create(&key).Error
+	})
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Could not create key", "data": err})
 	}
@@ -212,7 +316,7 @@ func CreateKey(c *fiber.Ctx) error {
 // @Produce json
 // @Param building_name body string true "building_name"
 // @Param room_name body string true "room_name"
-// @Param status body string true "status"
+// @Param cabinet_name body string false "cabinet_name"
 // @Success 200 {object} model.Key
 // @router /api/key/{rfid} [put]
 func UpdateKey(c *fiber.Ctx) error {
@@ -221,6 +325,8 @@ func UpdateKey(c *fiber.Ctx) error {
 		BuildingName string          `json:"building_name"`
 		RoomName     string          `json:"room_name"`
 		Status       model.KeyStatus `json:"status"`
+		// An empty cabinet name keeps the key in its cabinet
+		CabinetName string `json:"cabinet_name"`
 	}
 
 	db := database.DB
@@ -245,29 +351,81 @@ func UpdateKey(c *fiber.Ctx) error {
 		return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Review your input", "data": err})
 	}
 
-	// Create a temporary building data
-	var storedBuidling model.Building
-	db.Find(&storedBuidling, "name = ?", key_to_update.BuildingName)
-	// If building does not exists, return an error
-	if storedBuidling.ID == uuid.Nil {
-		return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Building does not exist.", "data": nil})
+	// The status only changes through borrows, returns and the lifecycle endpoints
+	if key_to_update.Status != "" && key_to_update.Status != key.Status {
+		return c.Status(409).JSON(fiber.Map{"status": "error", "code": "INVALID_TRANSITION", "message": "Use the key lifecycle endpoints to change its status", "data": nil})
 	}
 
+	// Master keys keep the building and rooms of their access group
+	master := key.AccessGroupID != uuid.Nil
+
+	// Create a temporary building data
+	var storedBuidling model.Building
 	// Create a temporary room data
 	var storedRoom model.Room
-	db.Find(&storedRoom, "name = ?", key_to_update.RoomName)
-	// If room does not exists, return an error
-	if storedRoom.ID == uuid.Nil {
-		return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Room does not exist.", "data": nil})
+	if !master {
+		db.Find(&storedBuidling, "name = ?", key_to_update.BuildingName)
+		// If building does not exists, return an error
+		if storedBuidling.ID == uuid.Nil {
+			return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Building does not exist.", "data": nil})
+		}
+
+		db.Find(&storedRoom, "name = ?", key_to_update.RoomName)
+		// If room does not exists, return an error
+		if storedRoom.ID == uuid.Nil {
+			return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Room does not exist.", "data": nil})
+		}
 	}
 
-	// Edit the key
-	key.BuildingID = storedBuidling.ID
-	key.RoomID = storedRoom.ID
-	key.Status = key_to_update.Status
+	// Create a temporary cabinet data
+	var storedCabinet model.Cabinet
+	if key_to_update.CabinetName != "" {
+		db.Find(&storedCabinet, "name = ?", key_to_update.CabinetName)
+		// If cabinet does not exists, return an error
+		if storedCabinet.ID == uuid.Nil {
+			return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Cabinet does not exist.", "data": nil})
+		}
+	}
 
-	// Save the Changes
-	db.Save(&key)
+	// Save the Changes, moving the key to the new room's key set
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Lock the key so that a concurrent borrow or return is not overwritten
+		var err error
+		key, err = lockKey(tx, rfid)
+		if err != nil {
+			return err
+		}
+
+		// Edit the key
+		if storedCabinet.ID != uuid.Nil && storedCabinet.ID != key.CabinetID {
+			// A key hanging in its old cabinet is moved along to the new one
+			if key.CurrentCabinetID == key.CabinetID {
+				key.CurrentCabinetID = storedCabinet.ID
+			}
+			key.CabinetID = storedCabinet.ID
+			key.CabinetName = storedCabinet.Name
+		}
+		if !master && key.RoomID != storedRoom.ID {
+			err = assignKeySet(tx, &key, storedRoom, storedBuidling)
+			if err != nil {
+				return err
+			}
+		}
+		if !master {
+			key.BuildingID = storedBuidling.ID
+			key.RoomID = storedRoom.ID
+			key.BuildingName = storedBuidling.Name
+			key.RoomName = storedRoom.Name
+			key.RoomFloor = storedRoom.Floor
+		}
+		return tx.Save(&key).Error
+	})
+	if _, ok := err.(*recordHandler.RecordError); ok {
+		return recordHandler.RespondErrorWith(c, err, "Could not update key")
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Could not update key", "data": err})
+	}
 
 	// Return the updated key
 	return c.JSON(fiber.Map{"status": "success", "message": "Key Updated", "data": key})
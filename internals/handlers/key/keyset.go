@@ -0,0 +1,146 @@
+package keyHandler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/vincemoke66/keyper-api/database"
+	recordHandler "github.com/vincemoke66/keyper-api/internals/handlers/record"
+	"github.com/vincemoke66/keyper-api/internals/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// KeySetSummary is a room's key set with how many of its copies are in or out
+type KeySetSummary struct {
+	model.KeySet
+	Total     int         `json:"total"`
+	Available int         `json:"available"`
+	Borrowed  int         `json:"borrowed"`
+	Other     int         `json:"other"`
+	Copies    []model.Key `json:"copies"`
+	// MasterKeys are the master keys that also open the room
+	MasterKeys []model.Key `json:"master_keys"`
+}
+
+// GetKeySetUsingRoomName func gets the key copies of a room
+// @Description Gets the key copies of a room and how many are available or out
+// @Tags Key
+// @Accept json
+// @Produce json
+// @Success 200 {object} KeySetSummary
+// @router /api/key/room/{room_name} [get]
+func GetKeySetUsingRoomName(c *fiber.Ctx) error {
+	db := database.DB
+
+	// Read the param room_name
+	room_name := c.Params("room_name")
+
+	// Create a temporary room data
+	var storedRoom model.Room
+	db.Find(&storedRoom, "name = ?", room_name)
+	// If room does not exist, return an error
+	if storedRoom.ID == uuid.Nil {
+		return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Room does not exist.", "data": nil})
+	}
+
+	// Find the key set of the room
+	var keySet model.KeySet
+	db.Find(&keySet, "room_id = ?", storedRoom.ID)
+	// If the room has no key, return an error
+	if keySet.ID == uuid.Nil {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Room has no key.", "data": nil})
+	}
+
+	// Return the key set with its copies
+	return c.JSON(fiber.Map{"status": "success", "message": "Key Set Found", "data": summarizeKeySet(db, keySet)})
+}
+
+// GetKeySetsUsingBuildingName func gets the key sets of every room in a building
+// @Description Gets how many key copies are available or out for every room in a building
+// @Tags Key
+// @Accept json
+// @Produce json
+// @Success 200 {array} KeySetSummary
+// @router /api/key/bn/{building_name}/rooms [get]
+func GetKeySetsUsingBuildingName(c *fiber.Ctx) error {
+	db := database.DB
+	var keySets []model.KeySet
+
+	// Read the param building_name
+	building_name := c.Params("building_name")
+
+	// Create a temporary building data
+	var storedBuilding model.Building
+	db.Find(&storedBuilding, "name = ?", building_name)
+	// If building does not exist, return an error
+	if storedBuilding.ID == uuid.Nil {
+		return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Building does not exist.", "data": nil})
+	}
+
+	// find all key sets in the building
+	db.Order("room_name ASC").Find(&keySets, "building_id = ?", storedBuilding.ID)
+
+	// If no key set is present return an error
+	if len(keySets) == 0 {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "No Keys data found", "data": nil})
+	}
+
+	summaries := make([]KeySetSummary, 0, len(keySets))
+	for _, keySet := range keySets {
+		summaries = append(summaries, summarizeKeySet(db, keySet))
+	}
+
+	// Else return key sets
+	return c.JSON(fiber.Map{"status": "success", "message": "Key Sets Found", "data": summaries})
+}
+
+// summarizeKeySet counts the copies of the key set by status
+func summarizeKeySet(db *gorm.DB, keySet model.KeySet) KeySetSummary {
+	summary := KeySetSummary{KeySet: keySet}
+
+	db.Order("copy_number ASC").Find(&summary.Copies, "key_set_id = ?", keySet.ID)
+	for _, key := range summary.Copies {
+		switch key.Status {
+		case model.KeyStatusAvailable:
+			summary.Available++
+		case model.KeyStatusBorrowed:
+			summary.Borrowed++
+		default:
+			summary.Other++
+		}
+	}
+	summary.Total = len(summary.Copies)
+
+	summary.MasterKeys = []model.Key{}
+	db.Order("room_name ASC").Find(&summary.MasterKeys, "id IN (?)", recordHandler.MasterKeysCovering(db, keySet.RoomName))
+
+	return summary
+}
+
+// assignKeySet adds key as the next copy of the room's key set, creating the
+// set if the room has none yet. The set is locked while numbering the copy.
+func assignKeySet(tx *gorm.DB, key *model.Key, room model.Room, building model.Building) error {
+	var keySet model.KeySet
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Find(&keySet, "room_id = ?", room.ID).Error
+	if err != nil {
+		return err
+	}
+
+	if keySet.ID == uuid.Nil {
+		keySet = model.KeySet{ID: uuid.New(), RoomID: room.ID, BuildingID: building.ID, RoomName: room.Name, BuildingName: building.Name}
+		err = tx.Create(&keySet).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	var lastCopy int
+	err = tx.Model(&model.Key{}).Where("key_set_id = ?", keySet.ID).Select("COALESCE(MAX(copy_number), 0)").Scan(&lastCopy).Error
+	if err != nil {
+		return err
+	}
+
+	key.KeySetID = keySet.ID
+	key.CopyNumber = lastCopy + 1
+	return nil
+}
@@ -0,0 +1,278 @@
+package keyHandler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/vincemoke66/keyper-api/database"
+	recordHandler "github.com/vincemoke66/keyper-api/internals/handlers/record"
+	"github.com/vincemoke66/keyper-api/internals/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReportKeyLost func marks a key as lost
+// @Description Marks an available or borrowed key as lost
+// @Tags Key
+// @Accept json
+// @Produce json
+// @Param note body string false "note"
+// @Success 200 {object} model.Key
+// @router /api/key/rfid/{rfid}/lost [post]
+func ReportKeyLost(c *fiber.Ctx) error {
+	return transitionKeyUsingRFID(c, "", model.KeyStatusLost, model.RecordTypeLost, "Key reported lost")
+}
+
+// MarkKeyFound func marks a lost key as found
+// @Description Marks a lost key as found and available again
+// @Tags Key
+// @Accept json
+// @Produce json
+// @Param note body string false "note"
+// @Success 200 {object} model.Key
+// @router /api/key/rfid/{rfid}/found [post]
+func MarkKeyFound(c *fiber.Ctx) error {
+	return transitionKeyUsingRFID(c, model.KeyStatusLost, model.KeyStatusAvailable, model.RecordTypeFound, "Key marked found")
+}
+
+// RetireKey func retires a key for good
+// @Description Retires a key that is not borrowed
+// @Tags Key
+// @Accept json
+// @Produce json
+// @Param note body string false "note"
+// @Success 200 {object} model.Key
+// @router /api/key/rfid/{rfid}/retire [post]
+func RetireKey(c *fiber.Ctx) error {
+	return transitionKeyUsingRFID(c, "", model.KeyStatusRetired, model.RecordTypeRetired, "Key retired")
+}
+
+// StartKeyMaintenance func puts a key into maintenance
+// @Description Puts an available key into maintenance
+// @Tags Key
+// @Accept json
+// @Produce json
+// @Param note body string false "note"
+// @Success 200 {object} model.Key
+// @router /api/key/rfid/{rfid}/maintenance [post]
+func StartKeyMaintenance(c *fiber.Ctx) error {
+	return transitionKeyUsingRFID(c, "", model.KeyStatusMaintenance, model.RecordTypeMaintenanceStart, "Key put into maintenance")
+}
+
+// EndKeyMaintenance func takes a key out of maintenance
+// @Description Takes a key out of maintenance and makes it available
+// @Tags Key
+// @Accept json
+// @Produce json
+// @Param note body string false "note"
+// @Success 200 {object} model.Key
+// @router /api/key/rfid/{rfid}/maintenance [delete]
+func EndKeyMaintenance(c *fiber.Ctx) error {
+	return transitionKeyUsingRFID(c, model.KeyStatusMaintenance, model.KeyStatusAvailable, model.RecordTypeMaintenanceEnd, "Key taken out of maintenance")
+}
+
+// ReplaceKey func replaces a lost key with a new one
+// @Description Creates a new key taking the place of a lost key, continuing its history
+// @Tags Key
+// @Accept json
+// @Produce json
+// @Param rfid body string true "rfid"
+// @Param note body string false "note"
+// @Success 200 {object} model.Key
+// @router /api/key/rfid/{rfid}/replace [post]
+func ReplaceKey(c *fiber.Ctx) error {
+	db := database.DB
+
+	type Replacement struct {
+		RFID string `json:"rfid"`
+		Note string `json:"note"`
+	}
+
+	replacement := new(Replacement)
+
+	// Parse the body to the replacement object
+	err := c.BodyParser(replacement)
+	// Return parse error if any
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Review your input", "data": err})
+	}
+	if replacement.RFID == "" {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Invalid input", "data": nil})
+	}
+
+	// Read the param rfid of the lost key
+	lost_rfid := c.Params("rfid")
+
+	newKey := new(model.Key)
+	err = db.Transaction(func(tx *gorm.DB) error {
+		lostKey, err := lockKey(tx, lost_rfid)
+		if err != nil {
+			return err
+		}
+
+		// Only lost keys are replaced
+		if lostKey.Status != model.KeyStatusLost {
+			return &recordHandler.RecordError{Status: 409, Code: "INVALID_TRANSITION", Message: "Only lost keys can be replaced"}
+		}
+
+		// The new key needs its own rfid
+		var storedKey model.Key
+		tx.Find(&storedKey, "rfid = ?", replacement.RFID)
+		if storedKey.ID != uuid.Nil {
+			return &recordHandler.RecordError{Status: 409, Message: "Key with the same rfid already exist."}
+		}
+
+		// Retire the lost key
+		_, err = TransitionKey(tx, &lostKey, model.KeyStatusRetired, model.RecordTypeRetired, "Replaced by "+replacement.RFID)
+		if err != nil {
+			return err
+		}
+
+		// The new key takes over the copy of the lost key
+		newKey.ID = uuid.New()
+		newKey.RFID = replacement.RFID
+		newKey.Status = model.KeyStatusAvailable
+		newKey.BuildingID = lostKey.BuildingID
+		newKey.RoomID = lostKey.RoomID
+		newKey.RoomName = lostKey.RoomName
+		newKey.RoomFloor = lostKey.RoomFloor
+		newKey.BuildingName = lostKey.BuildingName
+		newKey.KeySetID = lostKey.KeySetID
+		newKey.CopyNumber = lostKey.CopyNumber
+		newKey.ReplacesKeyID = lostKey.ID
+		newKey.AccessGroupID = lostKey.AccessGroupID
+		// The new key hangs in the cabinet of the lost key
+		newKey.CabinetID = lostKey.CabinetID
+		newKey.CabinetName = lostKey.CabinetName
+		newKey.CurrentCabinetID = lostKey.CabinetID
+
+		note := "Replaces " + lostKey.RFID
+		if replacement.Note != "" {
+			note += ": " + replacement.Note
+		}
+		record := lifecycleRecord(*newKey, model.RecordTypeReplacement, note)
+		newKey.LastRecordID = record.ID
+
+		err = tx.Create(newKey).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(record).Error
+	})
+	if err != nil {
+		return recordHandler.RespondErrorWith(c, err, "Could not replace key")
+	}
+
+	// Return the replacement key
+	return c.JSON(fiber.Map{"status": "success", "message": "Key replaced", "data": newKey})
+}
+
+// TransitionKey moves a locked key to status next and writes a record of
+// recordType for it. Keys lost while borrowed are recorded against their holder.
+func TransitionKey(tx *gorm.DB, key *model.Key, next model.KeyStatus, recordType model.RecordType, note string) (model.Record, error) {
+	if !key.Status.CanTransitionTo(next) {
+		return model.Record{}, &recordHandler.RecordError{Status: 409, Code: "INVALID_TRANSITION", Message: "Key cannot go from " + string(key.Status) + " to " + string(next)}
+	}
+
+	record := lifecycleRecord(*key, recordType, note)
+	if key.Status == model.KeyStatusBorrowed {
+		record.BorrowerType = key.HolderType
+		record.BorrowerID = key.HolderID
+		record.BorrowerSchoolID = key.HolderSchoolID
+		record.BorrowerName = key.HolderName
+		if key.HolderType == model.HolderTypeStudent {
+			record.StudentID = key.HolderID
+			record.StudentName = key.HolderName
+		}
+	}
+
+	key.Status = next
+	key.ClearHolder()
+	key.LastRecordID = record.ID
+	err := tx.Save(key).Error
+	if err != nil {
+		return model.Record{}, err
+	}
+
+	err = tx.Create(record).Error
+	if err != nil {
+		return model.Record{}, err
+	}
+
+	// Suspend students who keep losing keys
+	err = recordHandler.SuspendIfRepeated(tx, *record)
+	if err != nil {
+		return *record, err
+	}
+
+	// Hold a key that is back in use for the first one waiting for it
+	err = recordHandler.OfferKey(tx, *key)
+	return *record, err
+}
+
+// transitionKeyUsingRFID moves the key of the rfid param to status next and
+// responds with the key and the record written for it. A non empty from is
+// the only status the key may be moved from.
+func transitionKeyUsingRFID(c *fiber.Ctx, from model.KeyStatus, next model.KeyStatus, recordType model.RecordType, message string) error {
+	db := database.DB
+
+	type Transition struct {
+		Note string `json:"note"`
+	}
+
+	// The body is optional
+	transition := new(Transition)
+	if len(c.Body()) > 0 {
+		err := c.BodyParser(transition)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Review your input", "data": err})
+		}
+	}
+
+	// Read the param rfid
+	rfid := c.Params("rfid")
+
+	var key model.Key
+	var record model.Record
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		key, err = lockKey(tx, rfid)
+		if err != nil {
+			return err
+		}
+		if from != "" && key.Status != from {
+			return &recordHandler.RecordError{Status: 409, Code: "INVALID_TRANSITION", Message: "Key is " + string(key.Status) + ", not " + string(from)}
+		}
+		record, err = TransitionKey(tx, &key, next, recordType, transition.Note)
+		return err
+	})
+	if err != nil {
+		return recordHandler.RespondErrorWith(c, err, "Could not update key status")
+	}
+
+	return c.JSON(fiber.Map{"status": "success", "message": message, "data": fiber.Map{"key": key, "record": record}})
+}
+
+// lockKey finds the key with the given rfid and locks it until tx ends
+func lockKey(tx *gorm.DB, rfid string) (model.Key, error) {
+	var key model.Key
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Find(&key, "rfid = ?", rfid).Error
+	if err != nil {
+		return key, err
+	}
+	if key.ID == uuid.Nil {
+		return key, &recordHandler.RecordError{Status: 404, Message: "Key not found"}
+	}
+	return key, nil
+}
+
+// lifecycleRecord makes a record of a key changing status outside a borrow or return
+func lifecycleRecord(key model.Key, recordType model.RecordType, note string) *model.Record {
+	return &model.Record{
+		ID:           uuid.New(),
+		Type:         recordType,
+		KeyID:        key.ID,
+		RoomName:     key.RoomName,
+		BuildingName: key.BuildingName,
+		Note:         note,
+	}
+}
@@ -0,0 +1,183 @@
+package keyHandler
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/vincemoke66/keyper-api/database"
+	recordHandler "github.com/vincemoke66/keyper-api/internals/handlers/record"
+	"github.com/vincemoke66/keyper-api/internals/model"
+	"gorm.io/gorm"
+)
+
+// GetKeyWaitlist func gets the waitlist of a key
+// @Description Get who is waiting for a key in order, the first one may be holding it
+// @Tags Key
+// @Accept json
+// @Produce json
+// @Success 200 {array} model.WaitlistEntry
+// @router /api/key/rfid/{rfid}/waitlist [get]
+func GetKeyWaitlist(c *fiber.Ctx) error {
+	db := database.DB
+	var entries []model.WaitlistEntry
+
+	// Read the param rfid
+	rfid := c.Params("rfid")
+
+	// Create a temporary key data
+	var storedKey model.Key
+	db.Find(&storedKey, "rfid = ?", rfid)
+	// If key does not exist, return an error
+	if storedKey.ID == uuid.Nil {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Key not found", "data": nil})
+	}
+
+	// find everyone still waiting for or holding the key
+	db.Order("created_at").Find(&entries, "key_id = ? AND status IN ?", storedKey.ID, []model.WaitlistStatus{model.WaitlistStatusWaiting, model.WaitlistStatusHolding})
+
+	// If nobody is waiting return an error
+	if len(entries) == 0 {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "No Waitlist data found", "data": nil})
+	}
+
+	// Return the waitlist
+	return c.JSON(fiber.Map{"status": "success", "message": "Waitlist Found", "data": entries})
+}
+
+// JoinKeyWaitlist func queues for a borrowed key
+// @Description Queues the borrower for a borrowed key. When it is returned, the first one waiting is given a hold on it for WAITLIST_HOLD and notified.
+// @Tags Key
+// @Accept json
+// @Produce json
+// @Param borrower_type body string false "borrower_type"
+// @Param school_id body string true "school_id"
+// @Success 200 {object} model.WaitlistEntry
+// @router /api/key/rfid/{rfid}/waitlist [post]
+func JoinKeyWaitlist(c *fiber.Ctx) error {
+	db := database.DB
+
+	type EntryToAdd struct {
+		BorrowerType model.HolderType `json:"borrower_type"`
+		SchoolID     string           `json:"school_id"`
+	}
+
+	entry_to_add := new(EntryToAdd)
+
+	// Parse the body to the entry object
+	err := c.BodyParser(entry_to_add)
+	// Return parse error if any
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Review your input", "data": err})
+	}
+	if entry_to_add.SchoolID == "" {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Review your input", "data": nil})
+	}
+
+	// Read the param rfid
+	rfid := c.Params("rfid")
+
+	entry := new(model.WaitlistEntry)
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Lock the key so that it is not returned while joining its waitlist
+		key, err := lockKey(tx, rfid)
+		if err != nil {
+			return err
+		}
+
+		borrower, err := recordHandler.FindBorrower(tx, entry_to_add.BorrowerType, entry_to_add.SchoolID)
+		if err != nil {
+			return err
+		}
+
+		// Only keys that are out or held for someone else can be waited for
+		if key.Status == model.KeyStatusAvailable {
+			var held int64
+			tx.Model(&model.WaitlistEntry{}).Where("key_id = ? AND status = ? AND hold_until > ?", key.ID, model.WaitlistStatusHolding, time.Now()).Count(&held)
+			if held == 0 {
+				return &recordHandler.RecordError{Status: 409, Code: "KEY_AVAILABLE", Message: "Key is available, borrow it instead"}
+			}
+		} else if key.Status != model.KeyStatusBorrowed {
+			return &recordHandler.RecordError{Status: 409, Code: "KEY_NOT_AVAILABLE", Message: "Key is " + string(key.Status)}
+		}
+
+		if key.HolderType == borrower.Type && key.HolderID == borrower.ID {
+			return &recordHandler.RecordError{Status: 400, Message: "Key is already held by " + borrower.Name}
+		}
+
+		// Everyone is queued once per key
+		var queued int64
+		tx.Model(&model.WaitlistEntry{}).Where("key_id = ? AND holder_type = ? AND holder_id = ? AND status IN ?", key.ID, borrower.Type, borrower.ID, []model.WaitlistStatus{model.WaitlistStatusWaiting, model.WaitlistStatusHolding}).Count(&queued)
+		if queued > 0 {
+			return &recordHandler.RecordError{Status: 409, Message: borrower.Name + " is already waiting for the key"}
+		}
+
+		// Add a uuid to the new entry
+		entry.ID = uuid.New()
+
+		entry.KeyID = key.ID
+		entry.KeyRFID = key.RFID
+		entry.RoomName = key.RoomName
+		entry.BuildingName = key.BuildingName
+		entry.HolderType = borrower.Type
+		entry.HolderID = borrower.ID
+		entry.HolderSchoolID = borrower.SchoolID
+		entry.HolderName = borrower.Name
+		entry.Status = model.WaitlistStatusWaiting
+
+		return tx.Create(entry).Error
+	})
+	if err != nil {
+		return recordHandler.RespondErrorWith(c, err, "Could not join waitlist")
+	}
+
+	// Return the created entry
+	return c.JSON(fiber.Map{"status": "success", "message": "Joined Waitlist", "data": entry})
+}
+
+// LeaveKeyWaitlist func leaves the waitlist of a key
+// @Description Removes an entry from the waitlist of a key. A hold given up passes to the next one waiting.
+// @Tags Key
+// @Accept json
+// @Produce json
+// @Success 200 {object} model.WaitlistEntry
+// @router /api/key/rfid/{rfid}/waitlist/{id} [delete]
+func LeaveKeyWaitlist(c *fiber.Ctx) error {
+	db := database.DB
+
+	// Read the params rfid and id
+	rfid := c.Params("rfid")
+	id := c.Params("id")
+
+	var entry model.WaitlistEntry
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Lock the key so that the hold is not passed on twice
+		key, err := lockKey(tx, rfid)
+		if err != nil {
+			return err
+		}
+
+		tx.Find(&entry, "id = ? AND key_id = ? AND status IN ?", id, key.ID, []model.WaitlistStatus{model.WaitlistStatusWaiting, model.WaitlistStatusHolding})
+		if entry.ID == uuid.Nil {
+			return &recordHandler.RecordError{Status: 404, Message: "Waitlist entry not found"}
+		}
+
+		holding := entry.Status == model.WaitlistStatusHolding
+		entry.Status = model.WaitlistStatusCancelled
+		err = tx.Save(&entry).Error
+		if err != nil || !holding {
+			return err
+		}
+
+		// Pass the hold on to the next one waiting
+		return recordHandler.OfferKey(tx, key)
+	})
+	if err != nil {
+		return recordHandler.RespondErrorWith(c, err, "Could not leave waitlist")
+	}
+
+	go recordHandler.SendHoldNotices(db)
+
+	// Return the cancelled entry
+	return c.JSON(fiber.Map{"status": "success", "message": "Left Waitlist", "data": entry})
+}
@@ -0,0 +1,159 @@
+package limitHandler
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/vincemoke66/keyper-api/database"
+	"github.com/vincemoke66/keyper-api/internals/model"
+)
+
+// GetExceptions func gets all active borrow limit exceptions
+// @Description Get all borrow limit exceptions that have not expired
+// @Tags Limit
+// @Accept json
+// @Produce json
+// @Success 200 {array} model.BorrowLimitException
+// @router /api/limit/exception [get]
+func GetExceptions(c *fiber.Ctx) error {
+	db := database.DB
+	var exceptions []model.BorrowLimitException
+
+	// find all exceptions that have not expired
+	db.Order("created_at DESC").Find(&exceptions, "expires_at IS NULL OR expires_at > ?", time.Now())
+
+	// If no exception is present return an error
+	if len(exceptions) == 0 {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "No Exceptions data found", "data": nil})
+	}
+
+	// Else return exceptions
+	return c.JSON(fiber.Map{"status": "success", "message": "Exceptions Found", "data": exceptions})
+}
+
+// CreateException func grants a student an exception to the borrow limits
+// @Description Grants a student a higher key limit or waives the late return cooldown, in one building or everywhere
+// @Tags Limit
+// @Accept json
+// @Produce json
+// @Param school_id body string true "school_id"
+// @Param building_name body string false "building_name"
+// @Param max_keys body int false "max_keys"
+// @Param waive_cooldown body bool false "waive_cooldown"
+// @Param expires_at body string false "expires_at"
+// @Param reason body string true "reason"
+// @Param granted_by body string true "granted_by"
+// @Success 200 {object} model.BorrowLimitException
+// @router /api/limit/exception [post]
+func CreateException(c *fiber.Ctx) error {
+	db := database.DB
+	exception := new(model.BorrowLimitException)
+
+	type ExceptionToAdd struct {
+		SchoolID      string `json:"school_id"`
+		BuildingName  string `json:"building_name"`
+		MaxKeys       int    `json:"max_keys"`
+		WaiveCooldown bool   `json:"waive_cooldown"`
+		ExpiresAt     string `json:"expires_at"`
+		Reason        string `json:"reason"`
+		GrantedBy     string `json:"granted_by"`
+	}
+
+	exception_to_add := new(ExceptionToAdd)
+
+	// Parse the body to the exception object
+	err := c.BodyParser(exception_to_add)
+	// Return parse error if any
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Review your input", "data": err})
+	}
+
+	// An exception has to lift something and say why
+	if exception_to_add.MaxKeys < 0 || (exception_to_add.MaxKeys == 0 && !exception_to_add.WaiveCooldown) || exception_to_add.Reason == "" {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Review your input", "data": nil})
+	}
+
+	// Parse the expiry if one was given
+	if exception_to_add.ExpiresAt != "" {
+		expiresAt, err := time.Parse(time.RFC3339, exception_to_add.ExpiresAt)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Invalid expires_at", "data": nil})
+		}
+		exception.ExpiresAt = &expiresAt
+	}
+
+	// Create a temporary student data
+	var storedStudent model.Student
+	db.Find(&storedStudent, "school_id = ?", exception_to_add.SchoolID)
+	// If student does not exist, return an error
+	if storedStudent.ID == uuid.Nil {
+		return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Student does not exist.", "data": nil})
+	}
+
+	// Limit the exception to a building if one was given
+	if exception_to_add.BuildingName != "" {
+		var storedBuilding model.Building
+		db.Find(&storedBuilding, "name = ?", exception_to_add.BuildingName)
+		// If building does not exist, return an error
+		if storedBuilding.ID == uuid.Nil {
+			return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Building does not exist.", "data": nil})
+		}
+		exception.BuildingID = storedBuilding.ID
+		exception.BuildingName = storedBuilding.Name
+	}
+
+	// Add a uuid to the new exception
+	exception.ID = uuid.New()
+
+	exception.StudentID = storedStudent.ID
+	exception.SchoolID = storedStudent.SchoolID
+	exception.StudentName = storedStudent.LastName + ", " + storedStudent.FirstName
+	exception.MaxKeys = exception_to_add.MaxKeys
+	exception.WaiveCooldown = exception_to_add.WaiveCooldown
+	exception.Reason = exception_to_add.Reason
+	exception.GrantedBy = exception_to_add.GrantedBy
+
+	// Create the Exception and return error if encountered
+	err = db.Create(&exception).Error
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Could not create exception", "data": err})
+	}
+
+	// Return the created exception
+	return c.JSON(fiber.Map{"status": "success", "message": "Exception created", "data": exception})
+}
+
+// DeleteException delete a borrow limit exception by id
+// @Description Delete a borrow limit exception by id
+// @Tags Limit
+// @Accept json
+// @Produce json
+// @Success 200
+// @router /api/limit/exception/{id} [delete]
+func DeleteException(c *fiber.Ctx) error {
+	db := database.DB
+	var exception model.BorrowLimitException
+
+	// Read the param id
+	id := c.Params("id")
+
+	// Find the exception with the given id param
+	db.Find(&exception, "id = ?", id)
+
+	// If no such exception present return an error
+	if exception.ID == uuid.Nil {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Exception not found", "data": nil})
+	}
+
+	// Delete the exception
+	err := db.Delete(&exception, "id = ?", id).Error
+
+	// Return error if encountered
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to delete exception", "data": nil})
+	}
+
+	// Return success message
+	return c.JSON(fiber.Map{"status": "success", "message": "Exception Deleted"})
+}
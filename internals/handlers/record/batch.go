@@ -0,0 +1,151 @@
+package recordHandler
+
+import (
+	"sort"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/vincemoke66/keyper-api/database"
+	"github.com/vincemoke66/keyper-api/internals/model"
+	"gorm.io/gorm"
+)
+
+// maxBatchKeys is the most keys a single batch can borrow or return
+const maxBatchKeys = 50
+
+// BatchResult is the outcome of one key of a batch
+type BatchResult struct {
+	RFID    string        `json:"rfid"`
+	Status  string        `json:"status"`
+	Code    string        `json:"code,omitempty"`
+	Message string        `json:"message"`
+	Record  *model.Record `json:"record,omitempty"`
+}
+
+// CreateBatchRecord func borrows or returns several keys at once
+// @Description Borrows or returns every key in rfids for one borrower in a single transaction. If any key fails, none of them is borrowed or returned.
+// @Tags Record
+// @Accept json
+// @Produce json
+// @Param type body string true "type"
+// @Param borrower_type body string false "borrower_type"
+// @Param school_id body string true "school_id"
+// @Param rfids body []string true "rfids"
+// @Param due_at body string false "due_at"
+// @Param cabinet body string false "cabinet"
+// @Success 200 {array} BatchResult
+// @router /api/record/batch [post]
+func CreateBatchRecord(c *fiber.Ctx) error {
+	db := database.DB
+
+	type BatchToAdd struct {
+		Type         model.RecordType `json:"type"`
+		BorrowerType model.HolderType `json:"borrower_type"`
+		SchoolID     string           `json:"school_id"`
+		RFIDs        []string         `json:"rfids"`
+		DueAt        string           `json:"due_at"`
+		Cabinet      string           `json:"cabinet"`
+	}
+
+	batch_to_add := new(BatchToAdd)
+
+	// Parse the body to the batch object
+	err := c.BodyParser(batch_to_add)
+	// Return parse error if any
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Error parsing data", "data": err})
+	}
+
+	// Only borrows and returns are batched
+	if batch_to_add.Type != model.RecordTypeBorrow && batch_to_add.Type != model.RecordTypeReturn {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Invalid type", "data": nil})
+	}
+
+	// Every key is processed once, in rfid order so that concurrent batches
+	// lock their keys in the same order
+	var rfids []string
+	seen := map[string]bool{}
+	for _, rfid := range batch_to_add.RFIDs {
+		if !seen[rfid] {
+			seen[rfid] = true
+			rfids = append(rfids, rfid)
+		}
+	}
+	sort.Strings(rfids)
+
+	if len(rfids) == 0 || len(rfids) > maxBatchKeys {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Review your input", "data": nil})
+	}
+
+	// Borrow or return every key in a single transaction, going through all of
+	// them so that every failing key is reported
+	results := make([]BatchResult, len(rfids))
+	var failed *RecordError
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for i, rfid := range rfids {
+			results[i].RFID = rfid
+
+			record, err := ProcessRecord(tx, RecordToAdd{
+				Type:         batch_to_add.Type,
+				BorrowerType: batch_to_add.BorrowerType,
+				SchoolID:     batch_to_add.SchoolID,
+				RFID:         rfid,
+				DueAt:        batch_to_add.DueAt,
+				Cabinet:      batch_to_add.Cabinet,
+			})
+
+			recordErr, ok := err.(*RecordError)
+			switch {
+			case ok:
+				results[i].Status = "error"
+				results[i].Code = recordErr.Code
+				results[i].Message = recordErr.Message
+				if failed == nil {
+					failed = recordErr
+				}
+			case err != nil:
+				// Database errors abort the transaction, nothing more can run
+				return err
+			default:
+				results[i].Status = "success"
+				results[i].Message = "Record created"
+				results[i].Record = &record
+			}
+		}
+
+		if failed != nil {
+			return failed
+		}
+		return nil
+	})
+
+	if err != nil && err != error(failed) {
+		return RespondError(c, err)
+	}
+	if failed != nil {
+		// The keys that went through were rolled back along with the failed ones
+		for i := range results {
+			if results[i].Status == "success" {
+				results[i].Status = "rolled_back"
+				results[i].Message = "Not applied because another key failed"
+				results[i].Record = nil
+			}
+		}
+		return c.Status(failed.Status).JSON(fiber.Map{"status": "error", "message": "No key was " + pastTense(batch_to_add.Type), "data": results})
+	}
+
+	// Tell the next ones in the waitlists that the returned keys are held for them
+	if batch_to_add.Type == model.RecordTypeReturn {
+		go SendHoldNotices(db)
+	}
+
+	// Return the result of every key
+	return c.JSON(fiber.Map{"status": "success", "message": "Records created", "data": results})
+}
+
+// pastTense names what a batch of the record type does to its keys
+func pastTense(recordType model.RecordType) string {
+	if recordType == model.RecordTypeReturn {
+		return "returned"
+	}
+	return "borrowed"
+}
@@ -0,0 +1,103 @@
+package recordHandler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/vincemoke66/keyper-api/internals/model"
+)
+
+// postBatch sends the batch to CreateBatchRecord and reads its results
+func postBatch(t *testing.T, body fiber.Map) (int, []BatchResult) {
+	t.Helper()
+
+	app := fiber.New()
+	app.Post("/api/record/batch", CreateBatchRecord)
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("POST", "/api/record/batch", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var response struct {
+		Data []BatchResult `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, response.Data
+}
+
+func TestBatchRollsBackWhenAKeyFails(t *testing.T) {
+	f := newFixture(t)
+	first, second := f.addKey(t, f.room), f.addKey(t, f.room)
+
+	// A key someone else holds makes the whole batch fail
+	held := f.addKey(t, f.room)
+	_, err := f.borrow(held, f.addStudent(t, "BSCS", "A"))
+	mustSucceed(t, err)
+
+	rfids := []string{second.RFID, first.RFID, held.RFID, first.RFID}
+	status, results := postBatch(t, fiber.Map{"type": "borrow", "school_id": f.student.SchoolID, "rfids": rfids})
+	if status != 400 {
+		t.Errorf("status = %d, want 400", status)
+	}
+
+	// One result per key, in rfid order
+	want := []string{first.RFID, second.RFID, held.RFID}
+	sort.Strings(want)
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+	for i, result := range results {
+		if result.RFID != want[i] {
+			t.Errorf("result %d is for %s, want %s", i, result.RFID, want[i])
+		}
+		wantStatus := "rolled_back"
+		if result.RFID == held.RFID {
+			wantStatus = "error"
+		}
+		if result.Status != wantStatus || result.Record != nil {
+			t.Errorf("result for %s = %+v, want %s", result.RFID, result, wantStatus)
+		}
+	}
+
+	// Nothing of the batch was kept
+	for _, key := range []model.Key{first, second} {
+		if stored := f.reload(t, key); stored.Status != model.KeyStatusAvailable || stored.LastRecordID != key.LastRecordID {
+			t.Errorf("key %s = %+v, want it untouched", key.RFID, stored)
+		}
+	}
+	var records int64
+	f.tx.Model(&model.Record{}).Where("borrower_id = ?", f.student.ID).Count(&records)
+	if records != 0 {
+		t.Errorf("the batch left %d records", records)
+	}
+
+	// Without the held key the same batch goes through
+	status, results = postBatch(t, fiber.Map{"type": "borrow", "school_id": f.student.SchoolID, "rfids": []string{first.RFID, second.RFID}})
+	if status != 200 {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	for _, result := range results {
+		if result.Status != "success" || result.Record == nil {
+			t.Errorf("result for %s = %+v", result.RFID, result)
+		}
+	}
+	for _, key := range []model.Key{first, second} {
+		if stored := f.reload(t, key); stored.Status != model.KeyStatusBorrowed || stored.HolderID != f.student.ID {
+			t.Errorf("key %s = %+v, want it borrowed by the student", key.RFID, stored)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package recordHandler
+
+import (
+	"github.com/google/uuid"
+	"github.com/vincemoke66/keyper-api/internals/model"
+	"gorm.io/gorm"
+)
+
+// Borrower is any registered person who can hold a key
+type Borrower struct {
+	Type     model.HolderType `json:"type"`
+	ID       uuid.UUID        `json:"id"`
+	SchoolID string           `json:"school_id"`
+	Name     string           `json:"name"`
+	// MasterKeyAccess lets the borrower take master keys
+	MasterKeyAccess bool `json:"master_key_access"`
+	// Student is only set when the borrower is a student
+	Student model.Student `json:"-"`
+}
+
+// FindBorrower finds the borrower of the given type by school id, which is
+// the pass number for guests. An empty type means a student.
+func FindBorrower(db *gorm.DB, borrowerType model.HolderType, schoolID string) (Borrower, error) {
+	return findBorrower(db, borrowerType, "school_id = ?", schoolID)
+}
+
+// FindBorrowerByID finds the borrower of the given type by its uuid
+func FindBorrowerByID(db *gorm.DB, borrowerType model.HolderType, id uuid.UUID) (Borrower, error) {
+	return findBorrower(db, borrowerType, "id = ?", id)
+}
+
+// FindBorrowerByRFID finds whoever owns the given card, trying every kind of borrower
+func FindBorrowerByRFID(db *gorm.DB, rfid string) (Borrower, error) {
+	for _, borrowerType := range []model.HolderType{model.HolderTypeStudent, model.HolderTypeInstructor, model.HolderTypeStaff, model.HolderTypeGuest} {
+		borrower, err := findBorrower(db, borrowerType, "rfid = ?", rfid)
+		if err == nil {
+			return borrower, nil
+		}
+		if _, ok := err.(*RecordError); !ok {
+			return borrower, err
+		}
+	}
+	return Borrower{}, &RecordError{Status: 409, Message: "Card is not registered."}
+}
+
+func findBorrower(db *gorm.DB, borrowerType model.HolderType, query string, arg interface{}) (Borrower, error) {
+	var borrower Borrower
+
+	switch borrowerType {
+	case model.HolderTypeStudent, "":
+		var storedStudent model.Student
+		if err := db.Find(&storedStudent, query, arg).Error; err != nil {
+			return borrower, err
+		}
+		if storedStudent.ID == uuid.Nil {
+			return borrower, &RecordError{Status: 409, Message: "Student does not exist."}
+		}
+		borrower = Borrower{Type: model.HolderTypeStudent, ID: storedStudent.ID, SchoolID: storedStudent.SchoolID, Name: storedStudent.LastName + ", " + storedStudent.FirstName, Student: storedStudent}
+	case model.HolderTypeInstructor:
+		var storedInstructor model.Instructor
+		if err := db.Find(&storedInstructor, query, arg).Error; err != nil {
+			return borrower, err
+		}
+		if storedInstructor.ID == uuid.Nil {
+			return borrower, &RecordError{Status: 409, Message: "Instructor does not exist."}
+		}
+		borrower = Borrower{Type: model.HolderTypeInstructor, ID: storedInstructor.ID, SchoolID: storedInstructor.SchoolID, Name: storedInstructor.LastName + ", " + storedInstructor.FirstName, MasterKeyAccess: storedInstructor.MasterKeyAccess}
+	case model.HolderTypeStaff:
+		var storedStaff model.Staff
+		if err := db.Find(&storedStaff, query, arg).Error; err != nil {
+			return borrower, err
+		}
+		if storedStaff.ID == uuid.Nil {
+			return borrower, &RecordError{Status: 409, Message: "Staff does not exist."}
+		}
+		borrower = Borrower{Type: model.HolderTypeStaff, ID: storedStaff.ID, SchoolID: storedStaff.SchoolID, Name: storedStaff.LastName + ", " + storedStaff.FirstName, MasterKeyAccess: storedStaff.MasterKeyAccess}
+	case model.HolderTypeGuest:
+		// Guests are identified by their pass number instead of a school id
+		if query == "school_id = ?" {
+			query = "pass_number = ?"
+		}
+		var storedGuest model.Guest
+		if err := db.Find(&storedGuest, query, arg).Error; err != nil {
+			return borrower, err
+		}
+		if storedGuest.ID == uuid.Nil {
+			return borrower, &RecordError{Status: 409, Message: "Guest does not exist."}
+		}
+		borrower = Borrower{Type: model.HolderTypeGuest, ID: storedGuest.ID, SchoolID: storedGuest.PassNumber, Name: storedGuest.LastName + ", " + storedGuest.FirstName}
+	default:
+		return borrower, &RecordError{Status: 400, Message: "Invalid borrower_type"}
+	}
+
+	return borrower, nil
+}
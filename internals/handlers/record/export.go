@@ -0,0 +1,188 @@
+package recordHandler
+
+import (
+	"bufio"
+	"encoding/csv"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/vincemoke66/keyper-api/database"
+	"github.com/vincemoke66/keyper-api/internals/model"
+	"github.com/vincemoke66/keyper-api/internals/xlsx"
+	"gorm.io/gorm"
+)
+
+// BorrowRow is a borrow paired with the record that ended it, if any
+type BorrowRow struct {
+	BorrowerType     model.HolderType
+	BorrowerSchoolID string
+	BorrowerName     string
+	KeyRFID          string
+	RoomName         string
+	BuildingName     string
+	BorrowedAt       time.Time
+	DueAt            *time.Time
+	ReturnedAt       *time.Time
+}
+
+var exportHeader = []string{"Borrower Type", "School ID", "Borrower", "Key RFID", "Room", "Building", "Borrowed At", "Due At", "Returned At", "Duration (minutes)"}
+
+// ExportRecords func exports borrows with their returns as a spreadsheet
+// @Description Streams borrows paired with their returns as csv or xlsx, filtered like the record list
+// @Tags Record
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param format query string false "csv or xlsx"
+// @Param from query string false "from"
+// @Param to query string false "to"
+// @Param building query string false "building"
+// @Param room query string false "room"
+// @Param school_id query string false "school_id"
+// @Param key_rfid query string false "key_rfid"
+// @Success 200
+// @router /api/record/export [get]
+func ExportRecords(c *fiber.Ctx) error {
+	db := database.DB
+
+	// Read the filters
+	filter, err := ParseRecordFilter(c)
+	if err != nil {
+		return RespondErrorWith(c, err, "Could not export records")
+	}
+
+	format := c.Query("format", "csv")
+	if format != "csv" && format != "xlsx" {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Invalid format", "data": nil})
+	}
+
+	// Open the rows now so that query errors can still be reported
+	rows, err := BorrowRows(db, filter).Rows()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Could not export records", "data": err})
+	}
+
+	c.Attachment("records-" + time.Now().Format("20060102") + "." + format)
+	if format == "csv" {
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	}
+
+	// Stream the rows one at a time as the response is written
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer rows.Close()
+
+		var write func(cells []interface{}) error
+		var flush func() error
+		if format == "xlsx" {
+			sheet, err := xlsx.NewWriter(w, "Records")
+			if err != nil {
+				log.Println("Failed to export records:", err)
+				return
+			}
+			write = func(cells []interface{}) error { return sheet.WriteRow(cells...) }
+			flush = sheet.Close
+		} else {
+			sheet := csv.NewWriter(w)
+			write = func(cells []interface{}) error { return sheet.Write(csvCells(cells)) }
+			flush = func() error { sheet.Flush(); return sheet.Error() }
+		}
+
+		header := make([]interface{}, len(exportHeader))
+		for i, title := range exportHeader {
+			header[i] = title
+		}
+		if err := write(header); err != nil {
+			log.Println("Failed to export records:", err)
+			return
+		}
+
+		for rows.Next() {
+			var row BorrowRow
+			if err := db.ScanRows(rows, &row); err != nil {
+				log.Println("Failed to export records:", err)
+				return
+			}
+			if err := write(exportCells(row)); err != nil {
+				log.Println("Failed to export records:", err)
+				return
+			}
+		}
+
+		if err := flush(); err != nil {
+			log.Println("Failed to export records:", err)
+		}
+	})
+
+	return nil
+}
+
+// BorrowRows selects every borrow matching the filter along with the time
+// the key was returned, oldest first. Returns are paired in sql so that the
+// rows can be read one at a time. Transfers are skipped when pairing, so a
+// borrow lasts until the key is returned by whoever held it last.
+func BorrowRows(db *gorm.DB, filter RecordFilter) *gorm.DB {
+	return pairBorrows(db, filter).
+		Select(borrowColumns+`, (SELECT rfid FROM keys WHERE keys.id = records.key_id) AS key_rfid`, model.RecordTypeReturn).
+		Order("created_at ASC")
+}
+
+// BorrowStats selects the same borrows as BorrowRows, unordered and without
+// the key rfid, for statistics computed over them
+func BorrowStats(db *gorm.DB, filter RecordFilter) *gorm.DB {
+	return pairBorrows(db, filter).Select(borrowColumns, model.RecordTypeReturn)
+}
+
+// borrowColumns are the columns of a borrow paired with its return
+const borrowColumns = `borrower_type, borrower_school_id, borrower_name,
+	room_name, building_name, created_at AS borrowed_at, due_at,
+	CASE WHEN next_type = ? THEN next_at END AS returned_at`
+
+// pairBorrows filters the borrows, each with the type and time of the next
+// record of its key
+func pairBorrows(db *gorm.DB, filter RecordFilter) *gorm.DB {
+	paired := db.Model(&model.Record{}).Select(`records.*,
+		LEAD(type) OVER (PARTITION BY key_id ORDER BY created_at) AS next_type,
+		LEAD(created_at) OVER (PARTITION BY key_id ORDER BY created_at) AS next_at`).
+		Where("type <> ?", model.RecordTypeTransfer)
+
+	// Borrows are always selected, whatever the type filter says
+	filter.Type = model.RecordTypeBorrow
+
+	return filter.Apply(db.Table("(?) AS records", paired))
+}
+
+// exportCells lays out a borrow row in the order of the export header
+func exportCells(row BorrowRow) []interface{} {
+	var dueAt, returnedAt, duration interface{}
+	if row.DueAt != nil {
+		dueAt = *row.DueAt
+	}
+	if row.ReturnedAt != nil {
+		returnedAt = *row.ReturnedAt
+		duration = int64(row.ReturnedAt.Sub(row.BorrowedAt).Minutes())
+	}
+
+	return []interface{}{
+		string(row.BorrowerType), row.BorrowerSchoolID, row.BorrowerName, row.KeyRFID,
+		row.RoomName, row.BuildingName, row.BorrowedAt, dueAt, returnedAt, duration,
+	}
+}
+
+// csvCells formats cells as csv text
+func csvCells(cells []interface{}) []string {
+	record := make([]string, len(cells))
+	for i, cell := range cells {
+		switch v := cell.(type) {
+		case nil:
+			record[i] = ""
+		case string:
+			record[i] = v
+		case int64:
+			record[i] = strconv.FormatInt(v, 10)
+		case time.Time:
+			record[i] = v.Format(time.RFC3339)
+		}
+	}
+	return record
+}
@@ -0,0 +1,150 @@
+package recordHandler
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/vincemoke66/keyper-api/internals/model"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultRecordLimit = 50
+	maxRecordLimit     = 500
+)
+
+// RecordFilter narrows down records by the query params of a request
+type RecordFilter struct {
+	From         *time.Time
+	To           *time.Time
+	Type         model.RecordType
+	BuildingName string
+	RoomName     string
+	SchoolID     string
+	KeyRFID      string
+}
+
+// ParseRecordFilter reads the from, to, type, building, room, school_id and
+// key_rfid query params. Dates are either YYYY-MM-DD, where to includes the
+// whole day, or RFC3339 times.
+func ParseRecordFilter(c *fiber.Ctx) (RecordFilter, error) {
+	filter := RecordFilter{
+		Type:         model.RecordType(c.Query("type")),
+		BuildingName: c.Query("building"),
+		RoomName:     c.Query("room"),
+		SchoolID:     c.Query("school_id"),
+		KeyRFID:      c.Query("key_rfid"),
+	}
+
+	if from := c.Query("from"); from != "" {
+		t, _, err := parseDate(from)
+		if err != nil {
+			return filter, &RecordError{Status: 400, Message: "Invalid from"}
+		}
+		filter.From = &t
+	}
+
+	if to := c.Query("to"); to != "" {
+		t, dateOnly, err := parseDate(to)
+		if err != nil {
+			return filter, &RecordError{Status: 400, Message: "Invalid to"}
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		filter.To = &t
+	}
+
+	return filter, nil
+}
+
+// Apply adds the conditions of the filter to db. Column names are those of
+// the records table.
+func (f RecordFilter) Apply(db *gorm.DB) *gorm.DB {
+	if f.From != nil {
+		db = db.Where("created_at >= ?", *f.From)
+	}
+	if f.To != nil {
+		db = db.Where("created_at < ?", *f.To)
+	}
+	if f.Type != "" {
+		db = db.Where("type = ?", f.Type)
+	}
+	if f.BuildingName != "" {
+		db = db.Where("building_name = ?", f.BuildingName)
+	}
+	if f.RoomName != "" {
+		// Master keys covering the room are part of its history
+		db = db.Where("(room_name = ? OR key_id IN (?))", f.RoomName, MasterKeysCovering(db, f.RoomName))
+	}
+	if f.SchoolID != "" {
+		db = db.Where("borrower_school_id = ?", f.SchoolID)
+	}
+	if f.KeyRFID != "" {
+		db = db.Where("key_id IN (SELECT id FROM keys WHERE rfid = ?)", f.KeyRFID)
+	}
+	return db
+}
+
+// parseDate reads a YYYY-MM-DD date or an RFC3339 time, reporting which one it was
+func parseDate(value string) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	return t, false, err
+}
+
+// recordCursor points right after the last record of a page
+type recordCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+func encodeCursor(record model.Record) string {
+	raw := record.CreatedAt.Format(time.RFC3339Nano) + "|" + record.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(value string) (recordCursor, error) {
+	var cursor recordCursor
+
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor, err
+	}
+
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 {
+		return cursor, errors.New("malformed cursor")
+	}
+
+	cursor.CreatedAt, err = time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return cursor, err
+	}
+	cursor.ID, err = uuid.Parse(parts[1])
+	return cursor, err
+}
+
+// parseLimit reads the limit query param, keeping it within bounds
+func parseLimit(c *fiber.Ctx) (int, error) {
+	value := c.Query("limit")
+	if value == "" {
+		return defaultRecordLimit, nil
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 {
+		return 0, &RecordError{Status: 400, Message: "Invalid limit"}
+	}
+	if limit > maxRecordLimit {
+		limit = maxRecordLimit
+	}
+	return limit, nil
+}
@@ -0,0 +1,121 @@
+package recordHandler
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/vincemoke66/keyper-api/internals/model"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	record := model.Record{ID: uuid.New()}
+	record.CreatedAt = time.Date(2023, 5, 1, 8, 30, 0, 123456000, time.FixedZone("PHT", 8*60*60))
+
+	cursor, err := decodeCursor(encodeCursor(record))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cursor.ID != record.ID || !cursor.CreatedAt.Equal(record.CreatedAt) {
+		t.Errorf("cursor = %+v, want %s at %s", cursor, record.ID, record.CreatedAt)
+	}
+
+	for _, value := range []string{"not base64!", "bm8gc2VwYXJhdG9y", encodeCursor(model.Record{})[:10]} {
+		if _, err := decodeCursor(value); err == nil {
+			t.Errorf("decodeCursor(%q) did not fail", value)
+		}
+	}
+}
+
+// recordPage is the body of a page of GET /api/record
+type recordPage struct {
+	Data       []model.Record `json:"data"`
+	NextCursor string         `json:"next_cursor"`
+	Total      int64          `json:"total"`
+}
+
+func TestGetAllRecordsPages(t *testing.T) {
+	f := newFixture(t)
+	key := f.addKey(t, f.room)
+
+	// Records of the key, some made at the same time
+	base := time.Now().Add(-time.Hour).Truncate(time.Microsecond)
+	var records []model.Record
+	for i, offset := range []int{0, 1, 1, 1, 2, 3, 3} {
+		record := model.Record{ID: uuid.New(), Type: model.RecordTypeBorrow, KeyID: key.ID, RoomName: key.RoomName, BuildingName: key.BuildingName,
+			Note: "record " + string(rune('a'+i))}
+		record.CreatedAt = base.Add(time.Duration(offset) * time.Minute)
+		f.create(t, &record)
+		records = append(records, record)
+	}
+
+	// Newest first, the later id first among records made at the same time
+	sort.Slice(records, func(i, j int) bool {
+		if !records[i].CreatedAt.Equal(records[j].CreatedAt) {
+			return records[i].CreatedAt.After(records[j].CreatedAt)
+		}
+		return records[i].ID.String() > records[j].ID.String()
+	})
+
+	app := fiber.New()
+	app.Get("/api/record", GetAllRecords)
+	get := func(url string) (int, recordPage) {
+		t.Helper()
+		resp, err := app.Test(httptest.NewRequest("GET", url, nil), -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		var page recordPage
+		if resp.StatusCode == 200 {
+			if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp.StatusCode, page
+	}
+
+	// Following the cursors goes through every record once, in order
+	var seen []model.Record
+	url := "/api/record?limit=3&key_rfid=" + key.RFID
+	for pages := 0; ; pages++ {
+		if pages > len(records) {
+			t.Fatal("the cursors do not end")
+		}
+
+		status, page := get(url)
+		if status != 200 {
+			t.Fatalf("page %d got status %d", pages, status)
+		}
+		if page.Total != int64(len(records)) {
+			t.Errorf("total = %d, want %d", page.Total, len(records))
+		}
+		seen = append(seen, page.Data...)
+
+		if page.NextCursor == "" {
+			break
+		}
+		if len(page.Data) != 3 {
+			t.Errorf("page %d has %d records before the last page", pages, len(page.Data))
+		}
+		url = "/api/record?limit=3&key_rfid=" + key.RFID + "&cursor=" + page.NextCursor
+	}
+
+	if len(seen) != len(records) {
+		t.Fatalf("got %d records, want %d", len(seen), len(records))
+	}
+	for i := range records {
+		if seen[i].ID != records[i].ID {
+			t.Errorf("record %d is %s (%s), want %s (%s)", i, seen[i].ID, seen[i].Note, records[i].ID, records[i].Note)
+		}
+	}
+
+	if status, _ := get("/api/record?key_rfid=" + key.RFID + "&cursor=garbage"); status != 400 {
+		t.Errorf("invalid cursor got status %d, want 400", status)
+	}
+}
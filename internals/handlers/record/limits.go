@@ -0,0 +1,108 @@
+package recordHandler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vincemoke66/keyper-api/config"
+	"github.com/vincemoke66/keyper-api/internals/model"
+	"gorm.io/gorm"
+)
+
+// checkBorrowLimits refuses a borrow by a student who already holds as many
+// keys as allowed, or who returned a key late too recently. The global limits
+// count every building, the building limits only that building. An active
+// exception for the student replaces the limits it covers.
+func checkBorrowLimits(tx *gorm.DB, building model.Building, student model.Student) error {
+	now := time.Now()
+
+	// Find the exceptions granted to the student for this building or everywhere
+	var exceptions []model.BorrowLimitException
+	tx.Order("created_at DESC").Find(&exceptions, "student_id = ? AND (building_id = ? OR building_id IS NULL OR building_id = ?) AND (expires_at IS NULL OR expires_at > ?)",
+		student.ID, building.ID, uuid.Nil, now)
+
+	// The most recent exception of each kind applies
+	var everywhere, here *model.BorrowLimitException
+	for i := range exceptions {
+		if exceptions[i].BuildingID == uuid.Nil {
+			if everywhere == nil {
+				everywhere = &exceptions[i]
+			}
+		} else if here == nil {
+			here = &exceptions[i]
+		}
+	}
+
+	globalMax := config.Int("MAX_KEYS_PER_BORROWER", 0)
+	buildingMax := building.MaxKeysPerStudent
+	globalCooldown := config.Duration("LATE_RETURN_COOLDOWN", 0)
+	buildingCooldown := time.Duration(building.LateReturnCooldownMinutes) * time.Minute
+
+	// An exception for everywhere covers the global and the building limits,
+	// one for the building then takes precedence for the building limits
+	if everywhere != nil {
+		if everywhere.MaxKeys > 0 {
+			globalMax = everywhere.MaxKeys
+			buildingMax = everywhere.MaxKeys
+		}
+		if everywhere.WaiveCooldown {
+			globalCooldown = 0
+			buildingCooldown = 0
+		}
+	}
+	if here != nil {
+		if here.MaxKeys > 0 {
+			buildingMax = here.MaxKeys
+		}
+		if here.WaiveCooldown {
+			buildingCooldown = 0
+		}
+	}
+
+	// Count the keys the student holds right now
+	held := tx.Model(&model.Key{}).Where("status = ? AND holder_type = ? AND holder_id = ?", model.KeyStatusBorrowed, model.HolderTypeStudent, student.ID)
+	if globalMax > 0 {
+		var count int64
+		held.Session(&gorm.Session{}).Count(&count)
+		if count >= int64(globalMax) {
+			return &RecordError{Status: 403, Code: "BORROW_LIMIT_REACHED", Message: fmt.Sprintf("Student already holds %d of at most %d keys", count, globalMax)}
+		}
+	}
+	if buildingMax > 0 {
+		var count int64
+		held.Session(&gorm.Session{}).Where("building_id = ?", building.ID).Count(&count)
+		if count >= int64(buildingMax) {
+			return &RecordError{Status: 403, Code: "BORROW_LIMIT_REACHED", Message: fmt.Sprintf("Student already holds %d of at most %d keys in %s", count, buildingMax, building.Name)}
+		}
+	}
+
+	// Refuse the borrow until the cooldown after the latest late return ends
+	lateReturns := tx.Model(&model.Record{}).Where("type = ? AND late = ? AND borrower_type = ? AND borrower_id = ?", model.RecordTypeReturn, true, model.HolderTypeStudent, student.ID)
+	if globalCooldown > 0 {
+		err := checkCooldown(lateReturns.Session(&gorm.Session{}), now.Add(-globalCooldown), globalCooldown)
+		if err != nil {
+			return err
+		}
+	}
+	if buildingCooldown > 0 {
+		err := checkCooldown(lateReturns.Session(&gorm.Session{}).Where("building_name = ?", building.Name), now.Add(-buildingCooldown), buildingCooldown)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkCooldown refuses the borrow if one of the late returns was made after since
+func checkCooldown(lateReturns *gorm.DB, since time.Time, cooldown time.Duration) error {
+	var latest model.Record
+	lateReturns.Where("created_at > ?", since).Order("created_at DESC").Limit(1).Find(&latest)
+	if latest.ID == uuid.Nil {
+		return nil
+	}
+
+	until := latest.CreatedAt.Add(cooldown)
+	return &RecordError{Status: 403, Code: "COOLDOWN_ACTIVE", Message: "Student returned a key late and cannot borrow until " + until.Local().Format("2006-01-02 15:04")}
+}
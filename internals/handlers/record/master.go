@@ -0,0 +1,57 @@
+package recordHandler
+
+import (
+	"github.com/vincemoke66/keyper-api/internals/model"
+	"gorm.io/gorm"
+)
+
+// MasterKeysCovering selects the ids of the master keys whose access group
+// covers the room with the given name, to be used as a subquery
+func MasterKeysCovering(db *gorm.DB, roomName string) *gorm.DB {
+	return db.Session(&gorm.Session{NewDB: true}).Table("keys").Select("keys.id").
+		Joins("JOIN access_groups ON access_groups.id = keys.access_group_id AND access_groups.deleted_at IS NULL").
+		Joins("JOIN rooms ON rooms.name = ? AND rooms.deleted_at IS NULL", roomName).
+		Where("keys.deleted_at IS NULL AND access_groups.building_id = rooms.building_id").
+		Where(`(access_groups.scope = ? OR (access_groups.scope = ? AND access_groups.floor = rooms.floor) OR (access_groups.scope = ? AND EXISTS (
+			SELECT 1 FROM access_group_rooms WHERE access_group_rooms.access_group_id = access_groups.id
+			AND access_group_rooms.room_id = rooms.id AND access_group_rooms.deleted_at IS NULL)))`,
+			model.AccessGroupScopeBuilding, model.AccessGroupScopeFloor, model.AccessGroupScopeRooms)
+}
+
+// CoveredRooms selects the rooms opened by the master keys of the group
+func CoveredRooms(db *gorm.DB, group model.AccessGroup) *gorm.DB {
+	rooms := db.Model(&model.Room{}).Where("building_id = ?", group.BuildingID)
+	switch group.Scope {
+	case model.AccessGroupScopeBuilding:
+		return rooms
+	case model.AccessGroupScopeFloor:
+		return rooms.Where("floor = ?", group.Floor)
+	default:
+		listed := db.Session(&gorm.Session{NewDB: true}).Model(&model.AccessGroupRoom{}).Select("room_id").Where("access_group_id = ?", group.ID)
+		return rooms.Where("id IN (?)", listed)
+	}
+}
+
+// checkMasterKeyAccess only lets the borrower take a master key with an
+// access grant for every restricted room it opens
+func checkMasterKeyAccess(tx *gorm.DB, key model.Key, borrower Borrower) error {
+	var group model.AccessGroup
+	err := tx.Find(&group, "id = ?", key.AccessGroupID).Error
+	if err != nil {
+		return err
+	}
+
+	var restricted []model.Room
+	err = CoveredRooms(tx, group).Where("restricted = ?", true).Find(&restricted).Error
+	if err != nil {
+		return err
+	}
+
+	for _, room := range restricted {
+		err = checkRoomAccess(tx, room, borrower)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
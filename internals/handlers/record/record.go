@@ -1,33 +1,78 @@
 package recordHandler
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"github.com/vincemoke66/keyper-api/database"
+	attendanceHandler "github.com/vincemoke66/keyper-api/internals/handlers/attendance"
 	"github.com/vincemoke66/keyper-api/internals/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-// GetAllRecords func gets all records
-// @Description Gets all records
+// GetAllRecords func gets a page of records
+// @Description Gets a page of records, newest first, filtered by the query params
 // @Tags Record
 // @Accept json
 // @Produce json
+// @Param from query string false "from"
+// @Param to query string false "to"
+// @Param type query string false "type"
+// @Param building query string false "building"
+// @Param room query string false "room"
+// @Param school_id query string false "school_id"
+// @Param key_rfid query string false "key_rfid"
+// @Param limit query int false "limit"
+// @Param cursor query string false "cursor"
 // @Success 200 {array} model.Record
 // @router /api/record [get]
 func GetAllRecords(c *fiber.Ctx) error {
 	db := database.DB
 	var records []model.Record
 
-	// find all records
-	db.Order("created_at DESC").Find(&records)
+	// Read the filters and the page
+	filter, err := ParseRecordFilter(c)
+	if err != nil {
+		return RespondErrorWith(c, err, "Could not read records")
+	}
+	limit, err := parseLimit(c)
+	if err != nil {
+		return RespondErrorWith(c, err, "Could not read records")
+	}
+
+	// Count all records matching the filters
+	var total int64
+	filter.Apply(db.Model(&model.Record{})).Count(&total)
 
 	// If no record is present return an error
-	if len(records) == 0 {
+	if total == 0 {
 		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "No Records data found", "data": nil})
 	}
 
+	// find the page of records after the cursor
+	query := filter.Apply(db).Order("created_at DESC, id DESC").Limit(limit + 1)
+	if value := c.Query("cursor"); value != "" {
+		cursor, err := decodeCursor(value)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Invalid cursor", "data": nil})
+		}
+		query = query.Where("(created_at, id) < (?, ?)", cursor.CreatedAt, cursor.ID)
+	}
+	query.Find(&records)
+
+	// The extra record tells whether there is a next page
+	nextCursor := ""
+	if len(records) > limit {
+		records = records[:limit]
+		nextCursor = encodeCursor(records[limit-1])
+	}
+
 	// Else return records
-	return c.JSON(fiber.Map{"status": "success", "message": "Records Found", "data": records})
+	return c.JSON(fiber.Map{"status": "success", "message": "Records Found", "data": records, "next_cursor": nextCursor, "total": total})
 }
 
 // CreateRecord func creates a record
@@ -36,20 +81,16 @@ func GetAllRecords(c *fiber.Ctx) error {
 // @Accept json
 // @Produce json
 // @Param type body string true "type"
+// @Param borrower_type body string false "borrower_type"
 // @Param school_id body string true "school_id"
 // @Param key_rfid body string true "key_rfid"
+// @Param due_at body string false "due_at"
+// @Param note body string false "note"
+// @Param cabinet body string false "cabinet"
 // @Success 200 {object} model.Record
 // @router /api/record [post]
 func CreateRecord(c *fiber.Ctx) error {
 	db := database.DB
-	record := new(model.Record)
-
-	type RecordToAdd struct {
-		Type     model.RecordType `json:"type"`
-		SchoolID string           `json:"school_id"`
-		RFID     string           `json:"rfid"`
-	}
-
 	record_to_add := new(RecordToAdd)
 
 	// Parse the body to the key object
@@ -59,103 +100,456 @@ func CreateRecord(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Error parsing data", "data": err})
 	}
 
+	// Borrow or return the key in a single transaction
+	var record model.Record
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		record, err = ProcessRecord(tx, *record_to_add)
+		return err
+	})
+	if err != nil {
+		return RespondError(c, err)
+	}
+
+	// Tell the next one in the waitlist that the returned key is held for them
+	if record.Type == model.RecordTypeReturn {
+		go SendHoldNotices(db)
+	}
+
+	// Return the created record
+	return c.JSON(fiber.Map{"status": "success", "message": "Record created", "data": record})
+}
+
+// RecordToAdd is the input needed to borrow or return a key
+type RecordToAdd struct {
+	Type model.RecordType `json:"type"`
+	// BorrowerType defaults to a student, SchoolID is the pass number of guests
+	BorrowerType model.HolderType `json:"borrower_type"`
+	SchoolID     string           `json:"school_id"`
+	RFID         string           `json:"rfid"`
+	DueAt        string           `json:"due_at"`
+	Note         string           `json:"note"`
+	// Cabinet is the name of the cabinet the key is taken from or returned to
+	Cabinet string `json:"cabinet"`
+	// Correction marks the record of a key audit, which is not checked for
+	// lateness and does not count towards a suspension
+	Correction bool `json:"-"`
+}
+
+// RecordError is a rejected record along with the status to respond with.
+// Code names the rule that rejected it, if any.
+type RecordError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *RecordError) Error() string {
+	return e.Message
+}
+
+// RespondError writes err as a json error response. Errors that are not a
+// RecordError are reported as a failure to create the record.
+func RespondError(c *fiber.Ctx, err error) error {
+	return RespondErrorWith(c, err, "Could not create record")
+}
+
+// RespondErrorWith writes err as a json error response like RespondError,
+// reporting errors that are not a RecordError with message
+func RespondErrorWith(c *fiber.Ctx, err error, message string) error {
+	if recordErr, ok := err.(*RecordError); ok {
+		if recordErr.Code != "" {
+			return c.Status(recordErr.Status).JSON(fiber.Map{"status": "error", "code": recordErr.Code, "message": recordErr.Message, "data": nil})
+		}
+		return c.Status(recordErr.Status).JSON(fiber.Map{"status": "error", "message": recordErr.Message, "data": nil})
+	}
+	return c.Status(500).JSON(fiber.Map{"status": "error", "message": message, "data": err})
+}
+
+// ProcessRecord borrows or returns a key and writes its record using tx. The
+// key row stays locked until tx ends so that concurrent taps on the same key
+// are applied one after another.
+func ProcessRecord(tx *gorm.DB, record_to_add RecordToAdd) (model.Record, error) {
+	var record model.Record
+
 	if record_to_add.Type != "return" && record_to_add.SchoolID == "" {
-		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Review your input", "data": nil})
+		return record, &RecordError{Status: 400, Message: "Review your input"}
+	}
+
+	// Other record types are written by the key lifecycle endpoints
+	if record_to_add.Type != "borrow" && record_to_add.Type != "return" && record_to_add.Type != "transfer" {
+		return record, &RecordError{Status: 400, Message: "Invalid type"}
+	}
+
+	// Parse the requested due time if one was given
+	var requestedDueAt *time.Time
+	if record_to_add.DueAt != "" {
+		dueAt, err := time.Parse(time.RFC3339, record_to_add.DueAt)
+		if err != nil {
+			return record, &RecordError{Status: 400, Message: "Invalid due_at"}
+		}
+		requestedDueAt = &dueAt
 	}
 
-	// Create a temporary room data
-	var storedStudent model.Student
+	// Create a temporary borrower data
+	var borrower Borrower
 
 	// Create a temporary key data
 	var storedKey model.Key
-	// Find the key with the given school_id
-	db.Find(&storedKey, "rfid = ?", record_to_add.RFID)
+	// Find and lock the key with the given rfid
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Find(&storedKey, "rfid = ?", record_to_add.RFID).Error
+	if err != nil {
+		return record, err
+	}
 	// If key does not exists, return an error
 	if storedKey.ID == uuid.Nil {
-		return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Key does not exist.", "data": nil})
+		return record, &RecordError{Status: 409, Message: "Key does not exist."}
 	}
 
-	var latestRecord model.Record
+	// The key itself tells whether it is out and who holds it
+	if record_to_add.Type == "borrow" && storedKey.Status == "borrowed" {
+		return record, &RecordError{Status: 400, Message: "Key already borrowed"}
+	}
 
-	var records []model.Record
-	db.Find(&records)
-	if len(records) != 0 {
-		db.Order("created_at DESC").Where("key_id = ?", storedKey.ID).First(&latestRecord)
+	if record_to_add.Type == "return" && storedKey.Status == "available" {
+		return record, &RecordError{Status: 400, Message: "Key already returned"}
 	}
 
-	if record_to_add.Type == "borrow" && latestRecord.Type == "borrow" {
-		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Key already borrowed", "data": nil})
+	// Lost, retired or maintained keys can neither be borrowed nor returned
+	if record_to_add.Type == "borrow" && !storedKey.Status.CanTransitionTo(model.KeyStatusBorrowed) {
+		return record, &RecordError{Status: 409, Code: "KEY_NOT_AVAILABLE", Message: "Key is " + string(storedKey.Status)}
 	}
 
-	if record_to_add.Type == "borrow" && storedKey.Status == "borrowed" {
-		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Key already borrowed", "data": nil})
+	if (record_to_add.Type == "return" || record_to_add.Type == "transfer") && storedKey.Status != model.KeyStatusBorrowed {
+		return record, &RecordError{Status: 409, Code: "KEY_NOT_BORROWED", Message: "Key is " + string(storedKey.Status)}
 	}
 
-	if record_to_add.Type == "return" && storedKey.Status == "available" {
-		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Key already returned", "data": nil})
+	// Find the cabinet the key is taken from or returned to, if given
+	var storedCabinet model.Cabinet
+	if record_to_add.Cabinet != "" {
+		tx.Find(&storedCabinet, "name = ?", record_to_add.Cabinet)
+		// If cabinet does not exist, return an error
+		if storedCabinet.ID == uuid.Nil {
+			return record, &RecordError{Status: 409, Message: "Cabinet does not exist."}
+		}
 	}
 
-	if record_to_add.Type == "return" && latestRecord.Type == "return" {
-		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Key already returned", "data": nil})
+	// Keys go back to their own cabinet unless this one takes returns from anywhere
+	if record_to_add.Type == "return" && storedCabinet.ID != uuid.Nil && storedKey.CabinetID != uuid.Nil &&
+		storedCabinet.ID != storedKey.CabinetID && !storedCabinet.ReturnAnywhere {
+		return record, &RecordError{Status: 409, Code: "WRONG_CABINET", Message: "Return the key to cabinet " + storedKey.CabinetName}
 	}
 
 	if record_to_add.Type == "return" && record_to_add.SchoolID == "" {
-		if latestRecord.ID == uuid.Nil {
-			return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Review your input", "data": nil})
-		}
-		if latestRecord.Type == "return" {
-			return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Key already returned", "data": nil})
+		// Without a school id the key is returned by its holder
+		if storedKey.HolderID == uuid.Nil {
+			return record, &RecordError{Status: 400, Message: "Review your input"}
 		}
 
-		db.Find(&storedStudent, "id = ?", latestRecord.StudentID)
+		borrower, err = FindBorrowerByID(tx, storedKey.HolderType, storedKey.HolderID)
 	} else {
-		db.Find(&storedStudent, "school_id = ?", record_to_add.SchoolID)
+		borrower, err = FindBorrower(tx, record_to_add.BorrowerType, record_to_add.SchoolID)
 	}
 
-	// If student does not exist, return an error
-	if storedStudent.ID == uuid.Nil {
-		return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Student does not exist.", "data": nil})
+	// If borrower does not exist, return an error
+	if err != nil {
+		return record, err
 	}
 
+	// Master keys open the rooms of their access group instead of a single room
 	var storedRoom model.Room
-	db.Find(&storedRoom, "id = ?", storedKey.RoomID)
-	// If room does not exist, return an error
-	if storedRoom.ID == uuid.Nil {
-		return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Room does not exist.", "data": nil})
+	if storedKey.AccessGroupID != uuid.Nil {
+		storedRoom.Name = storedKey.RoomName
+	} else {
+		tx.Find(&storedRoom, "id = ?", storedKey.RoomID)
+		// If room does not exist, return an error
+		if storedRoom.ID == uuid.Nil {
+			return record, &RecordError{Status: 409, Message: "Room does not exist."}
+		}
 	}
 
 	var storedBuilding model.Building
-	db.Find(&storedBuilding, "id = ?", storedKey.BuildingID)
+	tx.Find(&storedBuilding, "id = ?", storedKey.BuildingID)
 	// If building does not exist, return an error
 	if storedBuilding.ID == uuid.Nil {
-		return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Building does not exist.", "data": nil})
+		return record, &RecordError{Status: 409, Message: "Building does not exist."}
+	}
+
+	// A key cannot be handed over to its own holder
+	if record_to_add.Type == "transfer" && borrower.Type == storedKey.HolderType && borrower.ID == storedKey.HolderID {
+		return record, &RecordError{Status: 400, Message: "Key is already held by " + borrower.Name}
+	}
+
+	// Whoever receives a transferred key has to be allowed to borrow it
+	if record_to_add.Type == "borrow" || record_to_add.Type == "transfer" {
+		// Only those given access can take a master key
+		if storedKey.AccessGroupID != uuid.Nil && !borrower.MasterKeyAccess {
+			return record, &RecordError{Status: 403, Code: "MASTER_KEY_NOT_ALLOWED", Message: borrower.Name + " is not allowed to borrow master keys"}
+		}
+
+		// Only those granted access can take the key of a restricted room,
+		// or a master key opening one
+		if storedKey.AccessGroupID != uuid.Nil {
+			err = checkMasterKeyAccess(tx, storedKey, borrower)
+		} else if storedRoom.Restricted {
+			err = checkRoomAccess(tx, storedRoom, borrower)
+		}
+		if err != nil {
+			return record, err
+		}
+
+		if borrower.Type == model.HolderTypeStudent {
+			// Refuse the borrow while the student is suspended
+			err = checkSuspension(tx, borrower.Student)
+			if err != nil {
+				return record, err
+			}
+
+			// Refuse the borrow when the student has no schedule in the room
+			err = checkSchedulePolicy(tx, storedRoom, storedBuilding, borrower.Student)
+			if err != nil {
+				return record, err
+			}
+
+			// Refuse the borrow when the student holds too many keys or is cooling down
+			err = checkBorrowLimits(tx, storedBuilding, borrower.Student)
+			if err != nil {
+				return record, err
+			}
+		}
+
+		// Refuse the borrow while someone else holds an active reservation
+		err = claimReservation(tx, storedKey, borrower)
+		if err != nil {
+			return record, err
+		}
+
+		// Refuse the borrow while the key is held for the next one in its waitlist
+		err = claimHold(tx, storedKey, borrower)
+		if err != nil {
+			return record, err
+		}
 	}
-	// Add a uuid to the new key
+
+	// Add a uuid to the new record
 	record.ID = uuid.New()
 
 	record.Type = record_to_add.Type
-	record.StudentID = storedStudent.ID
 	record.KeyID = storedKey.ID
 	record.RoomName = storedRoom.Name
 	record.BuildingName = storedBuilding.Name
-	record.StudentName = storedStudent.LastName + ", " + storedStudent.FirstName
+	record.Note = record_to_add.Note
+	record.Correction = record_to_add.Correction
+	record.CabinetID = storedCabinet.ID
+	record.CabinetName = storedCabinet.Name
+	setBorrower(&record, borrower)
 
 	// Update key status
 	if record.Type == "return" {
+		record.Late = !record.Correction && storedKey.DueAt != nil && time.Now().After(*storedKey.DueAt)
 		storedKey.Status = "available"
+		storedKey.ClearHolder()
+		// Keys returned without a cabinet are taken to be back in their own
+		storedKey.CurrentCabinetID = storedKey.CabinetID
+		if storedCabinet.ID != uuid.Nil {
+			storedKey.CurrentCabinetID = storedCabinet.ID
+		}
 	} else if record.Type == "borrow" {
+		record.DueAt = resolveDueAt(tx, requestedDueAt, storedRoom, storedBuilding)
 		storedKey.Status = "borrowed"
+		storedKey.CurrentCabinetID = uuid.Nil
+		setHolder(&storedKey, borrower, record.DueAt)
+	} else if record.Type == "transfer" {
+		// The new holder keeps the due time unless another one was given
+		record.DueAt = storedKey.DueAt
+		if requestedDueAt != nil {
+			record.DueAt = requestedDueAt
+		}
+		record.FromHolderType = storedKey.HolderType
+		record.FromHolderID = storedKey.HolderID
+		record.FromHolderSchoolID = storedKey.HolderSchoolID
+		record.FromHolderName = storedKey.HolderName
+		setHolder(&storedKey, borrower, record.DueAt)
+	}
+	storedKey.LastRecordID = record.ID
+	err = tx.Save(&storedKey).Error
+	if err != nil {
+		return record, err
 	}
-	db.Save(storedKey)
 
 	// Create the record and return error if encountered
-	err = db.Create(&record).Error
+	err = tx.Create(&record).Error
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Could not create record", "data": err})
+		return record, err
 	}
 
-	// Return the created record
-	return c.JSON(fiber.Map{"status": "success", "message": "Record created", "data": record})
+	// Suspend students who keep returning keys late
+	if !record.Correction {
+		err = SuspendIfRepeated(tx, record)
+		if err != nil {
+			return record, err
+		}
+	}
+
+	// Hold a returned key for the first one waiting for it
+	err = OfferKey(tx, storedKey)
+	if err != nil {
+		return record, err
+	}
+
+	return record, nil
+}
+
+// setBorrower fills in who borrowed or returned the key on the record
+func setBorrower(record *model.Record, borrower Borrower) {
+	record.BorrowerType = borrower.Type
+	record.BorrowerID = borrower.ID
+	record.BorrowerSchoolID = borrower.SchoolID
+	record.BorrowerName = borrower.Name
+	if borrower.Type == model.HolderTypeStudent {
+		record.StudentID = borrower.ID
+		record.StudentName = borrower.Name
+	}
+}
+
+// setHolder makes the borrower the current holder of the key
+func setHolder(key *model.Key, borrower Borrower, dueAt *time.Time) {
+	now := time.Now()
+	key.HolderType = borrower.Type
+	key.HolderID = borrower.ID
+	key.HolderSchoolID = borrower.SchoolID
+	key.HolderName = borrower.Name
+	key.BorrowedAt = &now
+	key.DueAt = dueAt
+	key.Overdue = false
+}
+
+// checkSchedulePolicy only lets a student borrow when the room or its building
+// requires a schedule and the student has one in the room around now. The
+// room's policy takes precedence over the building's.
+func checkSchedulePolicy(tx *gorm.DB, room model.Room, building model.Building, student model.Student) error {
+	required, graceBefore, graceAfter, rule := building.ScheduleRequired, building.ScheduleGraceBefore, building.ScheduleGraceAfter, "building "+building.Name
+	if room.ScheduleRequired != nil {
+		required, rule = *room.ScheduleRequired, "room "+room.Name
+	}
+	if room.ScheduleGraceBefore != nil {
+		graceBefore = *room.ScheduleGraceBefore
+	}
+	if room.ScheduleGraceAfter != nil {
+		graceAfter = *room.ScheduleGraceAfter
+	}
+	if !required {
+		return nil
+	}
+
+	// A schedule matches when it starts within graceBefore minutes from now
+	// or ended at most graceAfter minutes ago, on the same day
+	now := time.Now()
+	fromTime := clampToDay(now, now.Add(-time.Duration(graceAfter)*time.Minute))
+	toTime := clampToDay(now, now.Add(time.Duration(graceBefore)*time.Minute))
+
+	studentSchedules := schedulesOn(tx, now).Where("course = ? AND section = ?", student.Course, student.Section)
+	hasSchedule, _, err := attendanceHandler.CheckScheduleBetween(studentSchedules, fromTime, toTime, room.Name)
+	if err != nil {
+		return err
+	}
+
+	if !hasSchedule {
+		return &RecordError{
+			Status: 403,
+			Code:   "SCHEDULE_REQUIRED",
+			Message: fmt.Sprintf("The %s policy requires a schedule in %s between %s and %s (%d min before, %d min after)",
+				rule, room.Name, fromTime, toTime, graceBefore, graceAfter),
+		}
+	}
+
+	return nil
+}
+
+// checkRoomAccess only lets the borrower take the key of a restricted room
+// with an access grant for it that has not expired
+func checkRoomAccess(tx *gorm.DB, room model.Room, borrower Borrower) error {
+	var grants int64
+	err := tx.Model(&model.RoomAccessGrant{}).
+		Where("room_id = ? AND holder_type = ? AND holder_id = ?", room.ID, borrower.Type, borrower.ID).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Count(&grants).Error
+	if err != nil {
+		return err
+	}
+
+	if grants == 0 {
+		return &RecordError{Status: 403, Code: "ACCESS_GRANT_REQUIRED", Message: "Room " + room.Name + " is restricted and " + borrower.Name + " has no access grant"}
+	}
+
+	return nil
+}
+
+// schedulesOn narrows tx to the schedules held on the weekday of now, along
+// with those without a day
+func schedulesOn(tx *gorm.DB, now time.Time) *gorm.DB {
+	weekday := strings.ToLower(now.Weekday().String())
+	return tx.Where("(day_of_week = '' OR LOWER(day_of_week) IN ?)", []string{weekday, weekday[:3]})
+}
+
+// clampToDay formats t as HH:MM:SS, keeping it within the same day as now
+func clampToDay(now time.Time, t time.Time) string {
+	if t.YearDay() != now.YearDay() || t.Year() != now.Year() {
+		if t.Before(now) {
+			return "00:00:00"
+		}
+		return "23:59:59"
+	}
+	return t.Format("15:04:05")
+}
+
+// claimReservation checks the reservation holding the key right now, if any.
+// A borrow by its holder fulfills it, anyone else is refused.
+func claimReservation(tx *gorm.DB, key model.Key, borrower Borrower) error {
+	now := time.Now()
+
+	var reservation model.Reservation
+	tx.Limit(1).Find(&reservation, "key_id = ? AND status = ? AND starts_at <= ? AND ends_at > ?", key.ID, model.ReservationStatusActive, now, now)
+	if reservation.ID == uuid.Nil {
+		return nil
+	}
+
+	if reservation.HolderType != borrower.Type || reservation.HolderID != borrower.ID {
+		return &RecordError{Status: 409, Code: "KEY_RESERVED", Message: "Key is reserved for " + reservation.HolderName + " until " + reservation.EndsAt.Local().Format("15:04")}
+	}
+
+	reservation.Status = model.ReservationStatusFulfilled
+	return tx.Save(&reservation).Error
+}
+
+// resolveDueAt picks the expected return time of a borrow. A requested due
+// time wins, then the end of the room's current schedule, then the building
+// default. It returns nil when none of them apply.
+func resolveDueAt(tx *gorm.DB, requested *time.Time, room model.Room, building model.Building) *time.Time {
+	if requested != nil {
+		return requested
+	}
+
+	now := time.Now()
+
+	// Use the end time of the schedule currently running in the room
+	nowTime := now.Format("15:04:05")
+	hasSchedule, schedule, err := attendanceHandler.CheckScheduleBetween(schedulesOn(tx, now), nowTime, nowTime, room.Name)
+	if err == nil && hasSchedule {
+		endTime, err := time.ParseInLocation("15:04:05", schedule.EndTime, now.Location())
+		if err == nil {
+			dueAt := time.Date(now.Year(), now.Month(), now.Day(), endTime.Hour(), endTime.Minute(), endTime.Second(), 0, now.Location())
+			return &dueAt
+		}
+	}
+
+	// Fall back to the building default
+	if building.DefaultBorrowMinutes > 0 {
+		dueAt := now.Add(time.Duration(building.DefaultBorrowMinutes) * time.Minute)
+		return &dueAt
+	}
+
+	return nil
 }
 
 // GetStudent func get one student by school_id
@@ -0,0 +1,353 @@
+package recordHandler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vincemoke66/keyper-api/internals/model"
+	"github.com/vincemoke66/keyper-api/internals/testdb"
+	"gorm.io/gorm"
+)
+
+// fixture is a building with one room and a student, created in a
+// transaction that is rolled back when the test ends
+type fixture struct {
+	tx       *gorm.DB
+	suffix   string
+	building model.Building
+	room     model.Room
+	student  model.Student
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+
+	f := &fixture{tx: testdb.Begin(t), suffix: uuid.NewString()[:8]}
+	f.building = model.Building{ID: uuid.New(), Name: "Building " + f.suffix}
+	f.create(t, &f.building)
+	f.room = f.addRoom(t, "Room "+f.suffix)
+	f.student = f.addStudent(t, "BSCS", "A")
+	return f
+}
+
+func (f *fixture) create(t *testing.T, value interface{}) {
+	t.Helper()
+	if err := f.tx.Create(value).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (f *fixture) save(t *testing.T, value interface{}) {
+	t.Helper()
+	if err := f.tx.Save(value).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (f *fixture) addRoom(t *testing.T, name string) model.Room {
+	t.Helper()
+	room := model.Room{ID: uuid.New(), Name: name, Floor: 1, BuildingID: f.building.ID}
+	f.create(t, &room)
+	return room
+}
+
+func (f *fixture) addKey(t *testing.T, room model.Room) model.Key {
+	t.Helper()
+	key := model.Key{
+		ID:           uuid.New(),
+		RFID:         "K-" + uuid.NewString(),
+		Status:       model.KeyStatusAvailable,
+		BuildingID:   f.building.ID,
+		RoomID:       room.ID,
+		RoomName:     room.Name,
+		RoomFloor:    room.Floor,
+		BuildingName: f.building.Name,
+	}
+	f.create(t, &key)
+	return key
+}
+
+func (f *fixture) addStudent(t *testing.T, course string, section string) model.Student {
+	t.Helper()
+	student := model.Student{ID: uuid.New(), FirstName: "Test", LastName: f.suffix, SchoolID: "S-" + uuid.NewString(), Course: course, Section: section}
+	f.create(t, &student)
+	return student
+}
+
+func (f *fixture) borrow(key model.Key, student model.Student) (model.Record, error) {
+	return ProcessRecord(f.tx, RecordToAdd{Type: model.RecordTypeBorrow, SchoolID: student.SchoolID, RFID: key.RFID})
+}
+
+// returnLate returns the borrowed key after moving its due time to the past
+func (f *fixture) returnLate(t *testing.T, key model.Key) model.Record {
+	t.Helper()
+	err := f.tx.Model(&model.Key{}).Where("id = ?", key.ID).Update("due_at", time.Now().Add(-time.Hour)).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, err := ProcessRecord(f.tx, RecordToAdd{Type: model.RecordTypeReturn, RFID: key.RFID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !record.Late {
+		t.Fatal("return after the due time is not late")
+	}
+	return record
+}
+
+func (f *fixture) reload(t *testing.T, key model.Key) model.Key {
+	t.Helper()
+	var stored model.Key
+	if err := f.tx.Find(&stored, "id = ?", key.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	return stored
+}
+
+// expectCode fails unless err is a RecordError with the given code
+func expectCode(t *testing.T, err error, code string) {
+	t.Helper()
+	recordErr, ok := err.(*RecordError)
+	if !ok || recordErr.Code != code {
+		t.Fatalf("got error %v, want %s", err, code)
+	}
+}
+
+func mustSucceed(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("got error %v, want success", err)
+	}
+}
+
+func TestProcessRecordBorrowAndReturn(t *testing.T) {
+	f := newFixture(t)
+	key := f.addKey(t, f.room)
+
+	record, err := f.borrow(key, f.student)
+	mustSucceed(t, err)
+	if record.BorrowerID != f.student.ID || record.KeyID != key.ID {
+		t.Errorf("borrow record = %+v", record)
+	}
+
+	stored := f.reload(t, key)
+	if stored.Status != model.KeyStatusBorrowed || stored.HolderID != f.student.ID || stored.LastRecordID != record.ID {
+		t.Errorf("borrowed key = %+v", stored)
+	}
+
+	// A borrowed key cannot be borrowed again
+	other := f.addStudent(t, "BSCS", "A")
+	if _, err := f.borrow(key, other); err == nil {
+		t.Error("borrowed key was borrowed again")
+	}
+
+	// Without a school id the key is returned by its holder
+	record, err = ProcessRecord(f.tx, RecordToAdd{Type: model.RecordTypeReturn, RFID: key.RFID})
+	mustSucceed(t, err)
+	if record.BorrowerID != f.student.ID || record.Late {
+		t.Errorf("return record = %+v", record)
+	}
+
+	stored = f.reload(t, key)
+	if stored.Status != model.KeyStatusAvailable || stored.HolderID != uuid.Nil {
+		t.Errorf("returned key = %+v", stored)
+	}
+}
+
+func TestSchedulePolicy(t *testing.T) {
+	f := newFixture(t)
+	key := f.addKey(t, f.room)
+
+	f.building.ScheduleRequired = true
+	f.save(t, &f.building)
+
+	// Without a schedule in the room the student is refused
+	_, err := f.borrow(key, f.student)
+	expectCode(t, err, "SCHEDULE_REQUIRED")
+
+	today := time.Now().Weekday()
+	addSchedule := func(day time.Weekday, course string, section string) {
+		f.create(t, &model.Schedule{ID: uuid.New(), RoomName: f.room.Name, StartTime: "00:00:00", EndTime: "23:59:59",
+			DayOfWeek: day.String(), Course: course, Section: section})
+	}
+
+	// Schedules of another day or of other students do not count
+	addSchedule((today+1)%7, f.student.Course, f.student.Section)
+	addSchedule(today, f.student.Course, "Other "+f.suffix)
+	_, err = f.borrow(key, f.student)
+	expectCode(t, err, "SCHEDULE_REQUIRED")
+
+	// A schedule of the student's section in the room today does
+	addSchedule(today, f.student.Course, f.student.Section)
+	_, err = f.borrow(key, f.student)
+	mustSucceed(t, err)
+
+	// A room that does not require schedules overrides its building
+	free := f.addRoom(t, "Free "+f.suffix)
+	notRequired := false
+	free.ScheduleRequired = &notRequired
+	f.save(t, &free)
+	_, err = f.borrow(f.addKey(t, free), f.addStudent(t, "BSIT", "B"))
+	mustSucceed(t, err)
+}
+
+func TestBorrowLimits(t *testing.T) {
+	f := newFixture(t)
+	keys := []model.Key{f.addKey(t, f.room), f.addKey(t, f.room), f.addKey(t, f.room), f.addKey(t, f.room)}
+
+	// The global limit counts every building
+	t.Setenv("MAX_KEYS_PER_BORROWER", "1")
+	_, err := f.borrow(keys[0], f.student)
+	mustSucceed(t, err)
+	_, err = f.borrow(keys[1], f.student)
+	expectCode(t, err, "BORROW_LIMIT_REACHED")
+
+	// An exception for everywhere raises it
+	f.create(t, &model.BorrowLimitException{ID: uuid.New(), StudentID: f.student.ID, MaxKeys: 5})
+	_, err = f.borrow(keys[1], f.student)
+	mustSucceed(t, err)
+
+	// The building limit is replaced by the exception for the building even
+	// when the one for everywhere is newer
+	f.building.MaxKeysPerStudent = 1
+	f.save(t, &f.building)
+	err = f.tx.Model(&model.BorrowLimitException{}).Where("student_id = ?", f.student.ID).Update("created_at", time.Now().Add(time.Hour)).Error
+	mustSucceed(t, err)
+	f.create(t, &model.BorrowLimitException{ID: uuid.New(), StudentID: f.student.ID, BuildingID: f.building.ID, MaxKeys: 3})
+	_, err = f.borrow(keys[2], f.student)
+	mustSucceed(t, err)
+	_, err = f.borrow(keys[3], f.student)
+	expectCode(t, err, "BORROW_LIMIT_REACHED")
+}
+
+func TestLateReturnCooldown(t *testing.T) {
+	f := newFixture(t)
+	key := f.addKey(t, f.room)
+
+	f.building.LateReturnCooldownMinutes = 60
+	f.save(t, &f.building)
+
+	_, err := f.borrow(key, f.student)
+	mustSucceed(t, err)
+	f.returnLate(t, key)
+
+	// The student cools down after a late return
+	_, err = f.borrow(key, f.student)
+	expectCode(t, err, "COOLDOWN_ACTIVE")
+
+	// Other students are not affected
+	_, err = f.borrow(key, f.addStudent(t, "BSCS", "A"))
+	mustSucceed(t, err)
+	_, err = ProcessRecord(f.tx, RecordToAdd{Type: model.RecordTypeReturn, RFID: key.RFID})
+	mustSucceed(t, err)
+
+	// An exception for the building waives the cooldown
+	f.create(t, &model.BorrowLimitException{ID: uuid.New(), StudentID: f.student.ID, BuildingID: f.building.ID, WaiveCooldown: true})
+	_, err = f.borrow(key, f.student)
+	mustSucceed(t, err)
+}
+
+func TestSuspension(t *testing.T) {
+	f := newFixture(t)
+	key := f.addKey(t, f.room)
+
+	// Students are suspended on their second late return
+	t.Setenv("SUSPEND_AFTER_LATE_RETURNS", "2")
+	t.Setenv("SUSPENSION_DURATION", "24h")
+	for i := 0; i < 2; i++ {
+		_, err := f.borrow(key, f.student)
+		mustSucceed(t, err)
+		f.returnLate(t, key)
+	}
+
+	var suspension model.Suspension
+	mustSucceed(t, f.tx.Find(&suspension, "student_id = ?", f.student.ID).Error)
+	if suspension.Source != model.SuspensionSourceLateReturns || suspension.EndsAt == nil {
+		t.Errorf("suspension = %+v", suspension)
+	}
+
+	_, err := f.borrow(key, f.student)
+	expectCode(t, err, "SUSPENDED")
+
+	// Lifted suspensions no longer count
+	now := time.Now()
+	suspension.LiftedAt = &now
+	f.save(t, &suspension)
+	_, err = f.borrow(key, f.student)
+	mustSucceed(t, err)
+
+	// Nor do the ones that have not started yet
+	other := f.addStudent(t, "BSCS", "A")
+	mustSucceed(t, Suspend(f.tx, &model.Suspension{StudentID: other.ID, Reason: "Later", StartsAt: now.Add(time.Hour)}))
+	_, err = f.borrow(f.addKey(t, f.room), other)
+	mustSucceed(t, err)
+}
+
+// grant gives the holder access to the room until expiresAt, nil for good
+func (f *fixture) grant(t *testing.T, room model.Room, holderType model.HolderType, holderID uuid.UUID, expiresAt *time.Time) {
+	t.Helper()
+	f.create(t, &model.RoomAccessGrant{ID: uuid.New(), RoomID: room.ID, RoomName: room.Name, HolderType: holderType, HolderID: holderID,
+		ExpiresAt: expiresAt, GrantedByType: model.GrantedByAdmin, GrantedBy: "test"})
+}
+
+func TestRestrictedRoom(t *testing.T) {
+	f := newFixture(t)
+	f.room.Restricted = true
+	f.save(t, &f.room)
+	key := f.addKey(t, f.room)
+
+	_, err := f.borrow(key, f.student)
+	expectCode(t, err, "ACCESS_GRANT_REQUIRED")
+
+	// Grants of someone else or that expired do not count
+	past := time.Now().Add(-time.Minute)
+	f.grant(t, f.room, model.HolderTypeStudent, f.addStudent(t, "BSCS", "A").ID, nil)
+	f.grant(t, f.room, model.HolderTypeStudent, f.student.ID, &past)
+	_, err = f.borrow(key, f.student)
+	expectCode(t, err, "ACCESS_GRANT_REQUIRED")
+
+	future := time.Now().Add(time.Hour)
+	f.grant(t, f.room, model.HolderTypeStudent, f.student.ID, &future)
+	_, err = f.borrow(key, f.student)
+	mustSucceed(t, err)
+}
+
+func TestMasterKeyAccess(t *testing.T) {
+	f := newFixture(t)
+
+	// A master key opening the first floor, which has a restricted room
+	group := model.AccessGroup{ID: uuid.New(), Name: "Floor 1 " + f.suffix, BuildingID: f.building.ID, BuildingName: f.building.Name,
+		Scope: model.AccessGroupScopeFloor, Floor: 1}
+	f.create(t, &group)
+	key := model.Key{ID: uuid.New(), RFID: "K-" + uuid.NewString(), Status: model.KeyStatusAvailable, BuildingID: f.building.ID,
+		RoomName: group.Name, BuildingName: f.building.Name, AccessGroupID: group.ID}
+	f.create(t, &key)
+
+	restricted := f.addRoom(t, "Lab "+f.suffix)
+	restricted.Restricted = true
+	f.save(t, &restricted)
+	upstairs := f.addRoom(t, "Office "+f.suffix)
+	upstairs.Floor = 2
+	upstairs.Restricted = true
+	f.save(t, &upstairs)
+
+	// Students are never given master keys
+	_, err := f.borrow(key, f.student)
+	expectCode(t, err, "MASTER_KEY_NOT_ALLOWED")
+
+	instructor := model.Instructor{ID: uuid.New(), FirstName: "Test", LastName: f.suffix, SchoolID: "I-" + uuid.NewString(), MasterKeyAccess: true}
+	f.create(t, &instructor)
+	borrow := func() error {
+		_, err := ProcessRecord(f.tx, RecordToAdd{Type: model.RecordTypeBorrow, BorrowerType: model.HolderTypeInstructor, SchoolID: instructor.SchoolID, RFID: key.RFID})
+		return err
+	}
+
+	// Every restricted room the key opens needs a grant, the one upstairs is
+	// not opened by it
+	err = borrow()
+	expectCode(t, err, "ACCESS_GRANT_REQUIRED")
+
+	f.grant(t, restricted, model.HolderTypeInstructor, instructor.ID, nil)
+	mustSucceed(t, borrow())
+}
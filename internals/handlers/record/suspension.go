@@ -0,0 +1,111 @@
+package recordHandler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vincemoke66/keyper-api/config"
+	"github.com/vincemoke66/keyper-api/internals/model"
+	"gorm.io/gorm"
+)
+
+// ActiveSuspensions returns db filtered to the suspensions in effect at now
+func ActiveSuspensions(db *gorm.DB, now time.Time) *gorm.DB {
+	return db.Where("lifted_at IS NULL AND starts_at <= ? AND (ends_at IS NULL OR ends_at > ?)", now, now)
+}
+
+// Suspend creates the suspension along with the first event of its audit trail
+func Suspend(tx *gorm.DB, suspension *model.Suspension) error {
+	suspension.ID = uuid.New()
+	if suspension.StartsAt.IsZero() {
+		suspension.StartsAt = time.Now()
+	}
+
+	err := tx.Create(suspension).Error
+	if err != nil {
+		return err
+	}
+
+	return tx.Create(&model.SuspensionEvent{
+		ID:           uuid.New(),
+		SuspensionID: suspension.ID,
+		Action:       model.SuspensionEventCreated,
+		By:           suspension.CreatedBy,
+		Reason:       suspension.Reason,
+	}).Error
+}
+
+// checkSuspension refuses a borrow by a student who is suspended right now
+func checkSuspension(tx *gorm.DB, student model.Student) error {
+	var suspension model.Suspension
+	ActiveSuspensions(tx, time.Now()).Order("starts_at DESC").Limit(1).Find(&suspension, "student_id = ?", student.ID)
+	if suspension.ID == uuid.Nil {
+		return nil
+	}
+
+	until := "it is lifted"
+	if suspension.EndsAt != nil {
+		until = suspension.EndsAt.Local().Format("2006-01-02 15:04")
+	}
+	return &RecordError{Status: 403, Code: "SUSPENDED", Message: "Student is suspended from borrowing until " + until + ": " + suspension.Reason}
+}
+
+// SuspendIfRepeated suspends the student behind a late return or a lost key
+// once they reach SUSPEND_AFTER_LATE_RETURNS or SUSPEND_AFTER_LOST_KEYS within
+// SUSPENSION_WINDOW. The suspension lasts SUSPENSION_DURATION, or until it is
+// lifted when that is unset. Students already suspended are left as they are.
+func SuspendIfRepeated(tx *gorm.DB, record model.Record) error {
+	if record.BorrowerType != model.HolderTypeStudent {
+		return nil
+	}
+
+	var threshold int
+	var source model.SuspensionSource
+	var what string
+	offences := tx.Model(&model.Record{}).Where("borrower_type = ? AND borrower_id = ?", model.HolderTypeStudent, record.BorrowerID)
+	switch {
+	case record.Type == model.RecordTypeReturn && record.Late:
+		threshold = config.Int("SUSPEND_AFTER_LATE_RETURNS", 0)
+		source, what = model.SuspensionSourceLateReturns, "late returns"
+		offences = offences.Where("type = ? AND late = ?", model.RecordTypeReturn, true)
+	case record.Type == model.RecordTypeLost:
+		threshold = config.Int("SUSPEND_AFTER_LOST_KEYS", 0)
+		source, what = model.SuspensionSourceLostKeys, "lost keys"
+		offences = offences.Where("type = ?", model.RecordTypeLost)
+	}
+	if threshold <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	window := config.Duration("SUSPENSION_WINDOW", 30*24*time.Hour)
+
+	var count int64
+	offences.Where("created_at > ?", now.Add(-window)).Count(&count)
+	if count < int64(threshold) {
+		return nil
+	}
+
+	var active int64
+	ActiveSuspensions(tx.Model(&model.Suspension{}), now).Where("student_id = ?", record.BorrowerID).Count(&active)
+	if active > 0 {
+		return nil
+	}
+
+	suspension := model.Suspension{
+		StudentID:   record.BorrowerID,
+		SchoolID:    record.BorrowerSchoolID,
+		StudentName: record.BorrowerName,
+		Source:      source,
+		Reason:      fmt.Sprintf("%d %s in the last %s", count, what, window),
+		StartsAt:    now,
+		CreatedBy:   "system",
+	}
+	if duration := config.Duration("SUSPENSION_DURATION", 0); duration > 0 {
+		endsAt := now.Add(duration)
+		suspension.EndsAt = &endsAt
+	}
+
+	return Suspend(tx, &suspension)
+}
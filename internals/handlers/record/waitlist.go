@@ -0,0 +1,87 @@
+package recordHandler
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vincemoke66/keyper-api/config"
+	"github.com/vincemoke66/keyper-api/internals/model"
+	"github.com/vincemoke66/keyper-api/internals/notify"
+	"gorm.io/gorm"
+)
+
+// OfferKey gives the first one waiting for the key a hold on it for
+// WAITLIST_HOLD. It does nothing when the key is not available, is already
+// held or nobody is waiting. The key has to be locked by tx.
+func OfferKey(tx *gorm.DB, key model.Key) error {
+	if key.Status != model.KeyStatusAvailable {
+		return nil
+	}
+
+	now := time.Now()
+
+	// Someone else may still be holding the key
+	var held int64
+	err := tx.Model(&model.WaitlistEntry{}).Where("key_id = ? AND status = ? AND hold_until > ?", key.ID, model.WaitlistStatusHolding, now).Count(&held).Error
+	if err != nil || held > 0 {
+		return err
+	}
+
+	var entry model.WaitlistEntry
+	err = tx.Order("created_at").Limit(1).Find(&entry, "key_id = ? AND status = ?", key.ID, model.WaitlistStatusWaiting).Error
+	if err != nil || entry.ID == uuid.Nil {
+		return err
+	}
+
+	holdUntil := now.Add(config.Duration("WAITLIST_HOLD", 5*time.Minute))
+	entry.Status = model.WaitlistStatusHolding
+	entry.HoldUntil = &holdUntil
+	entry.NotifiedAt = nil
+	return tx.Save(&entry).Error
+}
+
+// claimHold checks the waitlist hold on the key, if any. Anyone but the one
+// holding it is refused, whoever gets the key leaves its queue.
+func claimHold(tx *gorm.DB, key model.Key, borrower Borrower) error {
+	var hold model.WaitlistEntry
+	tx.Limit(1).Find(&hold, "key_id = ? AND status = ? AND hold_until > ?", key.ID, model.WaitlistStatusHolding, time.Now())
+	if hold.ID != uuid.Nil && (hold.HolderType != borrower.Type || hold.HolderID != borrower.ID) {
+		return &RecordError{Status: 409, Code: "KEY_HELD", Message: "Key is held for " + hold.HolderName + " until " + hold.HoldUntil.Local().Format("15:04")}
+	}
+
+	return tx.Model(&model.WaitlistEntry{}).
+		Where("key_id = ? AND holder_type = ? AND holder_id = ? AND status IN ?", key.ID, borrower.Type, borrower.ID, []model.WaitlistStatus{model.WaitlistStatusWaiting, model.WaitlistStatusHolding}).
+		Update("status", model.WaitlistStatusFulfilled).Error
+}
+
+// SendHoldNotices tells everyone given a hold that the key is theirs to take.
+// It is called once the hold is committed so that nobody is told about a
+// hold that was rolled back. Notices that fail are sent again next time.
+func SendHoldNotices(db *gorm.DB) {
+	var entries []model.WaitlistEntry
+	db.Find(&entries, "status = ? AND notified_at IS NULL AND hold_until > ?", model.WaitlistStatusHolding, time.Now())
+
+	for _, entry := range entries {
+		// Claim the notice so that it is only sent once
+		result := db.Model(&model.WaitlistEntry{}).Where("id = ? AND notified_at IS NULL", entry.ID).Update("notified_at", time.Now())
+		if result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+
+		err := notify.Send(notify.Message{
+			HolderType: entry.HolderType,
+			HolderID:   entry.HolderID,
+			SchoolID:   entry.HolderSchoolID,
+			Name:       entry.HolderName,
+			Subject:    "The key of " + entry.RoomName + " is ready",
+			Body: fmt.Sprintf("The key of %s in %s was returned and is held for you until %s.",
+				entry.RoomName, entry.BuildingName, entry.HoldUntil.Local().Format("15:04")),
+		})
+		if err != nil {
+			log.Println("Failed to notify "+entry.HolderName+":", err)
+			db.Model(&model.WaitlistEntry{}).Where("id = ?", entry.ID).Update("notified_at", nil)
+		}
+	}
+}
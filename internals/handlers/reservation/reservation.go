@@ -0,0 +1,262 @@
+package reservationHandler
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/vincemoke66/keyper-api/database"
+	recordHandler "github.com/vincemoke66/keyper-api/internals/handlers/record"
+	"github.com/vincemoke66/keyper-api/internals/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetReservations func gets all existing reservations
+// @Description Get all existing reservations
+// @Tags Reservation
+// @Accept json
+// @Produce json
+// @Success 200 {array} model.Reservation
+// @router /api/reservation [get]
+func GetReservations(c *fiber.Ctx) error {
+	db := database.DB
+	var reservations []model.Reservation
+
+	// find all reservations in the database
+	db.Order("starts_at ASC").Find(&reservations)
+
+	// If no reservation is present return an error
+	if len(reservations) == 0 {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "No Reservations data found", "data": nil})
+	}
+
+	// Else return reservations
+	return c.JSON(fiber.Map{"status": "success", "message": "Reservations Found", "data": reservations})
+}
+
+// GetReservationsUsingRFID func gets all active reservations of a key
+// @Description Get all active reservations of a key
+// @Tags Reservation
+// @Accept json
+// @Produce json
+// @Success 200 {array} model.Reservation
+// @router /api/reservation/rfid/{rfid} [get]
+func GetReservationsUsingRFID(c *fiber.Ctx) error {
+	db := database.DB
+	var reservations []model.Reservation
+
+	// Read the param rfid
+	rfid := c.Params("rfid")
+
+	// Create a temporary key data
+	var storedKey model.Key
+	db.Find(&storedKey, "rfid = ?", rfid)
+	// If key does not exist, return an error
+	if storedKey.ID == uuid.Nil {
+		return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Key does not exist.", "data": nil})
+	}
+
+	// find all active reservations of the key that have not ended yet
+	db.Order("starts_at ASC").Find(&reservations, "key_id = ? AND status = ? AND ends_at > ?", storedKey.ID, model.ReservationStatusActive, time.Now())
+
+	// If no reservation is present return an error
+	if len(reservations) == 0 {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "No Reservations data found", "data": nil})
+	}
+
+	// Else return reservations
+	return c.JSON(fiber.Map{"status": "success", "message": "Reservations Found", "data": reservations})
+}
+
+// CreateReservation func creates a reservation
+// @Description Reserves a key for a time window
+// @Tags Reservation
+// @Accept json
+// @Produce json
+// @Param key_rfid body string true "key_rfid"
+// @Param holder_type body string true "holder_type"
+// @Param school_id body string true "school_id"
+// @Param starts_at body string true "starts_at"
+// @Param ends_at body string true "ends_at"
+// @Param schedule_id body string false "schedule_id"
+// @Success 200 {object} model.Reservation
+// @router /api/reservation [post]
+func CreateReservation(c *fiber.Ctx) error {
+	db := database.DB
+	reservation := new(model.Reservation)
+
+	type ReservationToAdd struct {
+		KeyRFID    string           `json:"key_rfid"`
+		HolderType model.HolderType `json:"holder_type"`
+		SchoolID   string           `json:"school_id"`
+		StartsAt   string           `json:"starts_at"`
+		EndsAt     string           `json:"ends_at"`
+		ScheduleID string           `json:"schedule_id"`
+	}
+
+	reservation_to_add := new(ReservationToAdd)
+
+	// Parse the body to the reservation object
+	err := c.BodyParser(reservation_to_add)
+	// Return parse error if any
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Review your input", "data": err})
+	}
+
+	// Parse the reserved time window
+	startsAt, err := time.Parse(time.RFC3339, reservation_to_add.StartsAt)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Invalid starts_at", "data": nil})
+	}
+	endsAt, err := time.Parse(time.RFC3339, reservation_to_add.EndsAt)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Invalid ends_at", "data": nil})
+	}
+	if !endsAt.After(startsAt) || !endsAt.After(time.Now()) {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Invalid reservation window", "data": nil})
+	}
+
+	// Create a temporary key data
+	var storedKey model.Key
+	db.Find(&storedKey, "rfid = ?", reservation_to_add.KeyRFID)
+	// If key does not exist, return an error
+	if storedKey.ID == uuid.Nil {
+		return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Key does not exist.", "data": nil})
+	}
+
+	// Find the person reserving the key
+	holder, err := recordHandler.FindBorrower(db, reservation_to_add.HolderType, reservation_to_add.SchoolID)
+	if err != nil {
+		return recordHandler.RespondErrorWith(c, err, "Could not create reservation")
+	}
+
+	// Check the window against the schedule slot it is made for
+	if reservation_to_add.ScheduleID != "" {
+		scheduleID, err := uuid.Parse(reservation_to_add.ScheduleID)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Invalid schedule_id", "data": nil})
+		}
+
+		var storedSchedule model.Schedule
+		db.Find(&storedSchedule, "id = ?", scheduleID)
+		if storedSchedule.ID == uuid.Nil {
+			return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Schedule does not exist.", "data": nil})
+		}
+		if storedSchedule.RoomName != storedKey.RoomName {
+			return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Schedule is not in the key's room.", "data": nil})
+		}
+		if !withinSchedule(storedSchedule, startsAt, endsAt) {
+			return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Reservation is outside the schedule.", "data": nil})
+		}
+
+		reservation.ScheduleID = storedSchedule.ID
+	}
+
+	// Add a uuid to the new reservation
+	reservation.ID = uuid.New()
+
+	reservation.KeyID = storedKey.ID
+	reservation.HolderType = holder.Type
+	reservation.HolderID = holder.ID
+	reservation.HolderSchoolID = holder.SchoolID
+	reservation.HolderName = holder.Name
+	reservation.KeyRFID = storedKey.RFID
+	reservation.RoomName = storedKey.RoomName
+	reservation.BuildingName = storedKey.BuildingName
+	reservation.StartsAt = startsAt
+	reservation.EndsAt = endsAt
+	reservation.Status = model.ReservationStatusActive
+
+	// Check for overlaps and create the Reservation while the key is locked,
+	// so that concurrent requests cannot reserve the same window
+	var overlapping model.Reservation
+	err = db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Find(&storedKey, "id = ?", storedKey.ID).Error
+		if err != nil {
+			return err
+		}
+
+		// Check that no other active reservation overlaps the window
+		err = tx.Limit(1).Find(&overlapping, "key_id = ? AND status = ? AND starts_at < ? AND ends_at > ?", storedKey.ID, model.ReservationStatusActive, endsAt, startsAt).Error
+		if err != nil || overlapping.ID != uuid.Nil {
+			return err
+		}
+
+		return tx.Create(&reservation).Error
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Could not create reservation", "data": err})
+	}
+	if overlapping.ID != uuid.Nil {
+		return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Key is already reserved for that time.", "data": overlapping})
+	}
+
+	// Return the created reservation
+	return c.JSON(fiber.Map{"status": "success", "message": "Reservation created", "data": reservation})
+}
+
+// CancelReservation cancel a reservation by id
+// @Description Cancel a Reservation by id
+// @Tags Reservation
+// @Accept json
+// @Produce json
+// @Success 200 {object} model.Reservation
+// @router /api/reservation/{id} [delete]
+func CancelReservation(c *fiber.Ctx) error {
+	db := database.DB
+	var reservation model.Reservation
+
+	// Read the param id
+	id := c.Params("id")
+
+	// Find the reservation with the given id param
+	db.Find(&reservation, "id = ?", id)
+
+	// If no such reservation present return an error
+	if reservation.ID == uuid.Nil {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Reservation not found", "data": nil})
+	}
+
+	// Only active reservations can be cancelled
+	if reservation.Status != model.ReservationStatusActive {
+		return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Reservation is no longer active", "data": reservation})
+	}
+
+	// Cancel the reservation
+	reservation.Status = model.ReservationStatusCancelled
+	err := db.Save(&reservation).Error
+
+	// Return error if encountered
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to cancel reservation", "data": nil})
+	}
+
+	// Return the cancelled reservation
+	return c.JSON(fiber.Map{"status": "success", "message": "Reservation Cancelled", "data": reservation})
+}
+
+// withinSchedule reports whether the window falls inside one occurrence of
+// the schedule, on its day of the week and between its start and end times
+func withinSchedule(schedule model.Schedule, startsAt time.Time, endsAt time.Time) bool {
+	startsAt = startsAt.Local()
+	endsAt = endsAt.Local()
+
+	// The window must be on a single day
+	if startsAt.YearDay() != endsAt.YearDay() || startsAt.Year() != endsAt.Year() {
+		return false
+	}
+
+	// The day must match when the schedule has one, e.g. "Monday" or "Mon"
+	if schedule.DayOfWeek != "" {
+		weekday := strings.ToLower(startsAt.Weekday().String())
+		day := strings.ToLower(schedule.DayOfWeek)
+		if day != weekday && day != weekday[:3] {
+			return false
+		}
+	}
+
+	// Times are compared as HH:MM:SS like the rest of the schedule queries
+	return startsAt.Format("15:04:05") >= schedule.StartTime && endsAt.Format("15:04:05") <= schedule.EndTime
+}
@@ -75,6 +75,10 @@ func GetRoomsOnBuilding(c *fiber.Ctx) error {
 // @Param name body string true "name"
 // @Param floor body int true "floor"
 // @Param building_name body string true "building_name"
+// @Param schedule_required body bool false "schedule_required"
+// @Param schedule_grace_before body int false "schedule_grace_before"
+// @Param schedule_grace_after body int false "schedule_grace_after"
+// @Param restricted body bool false "restricted"
 // @Success 200 {object} model.Room
 // @router /api/room [post]
 func CreateRoom(c *fiber.Ctx) error {
@@ -86,6 +90,13 @@ func CreateRoom(c *fiber.Ctx) error {
 		Name         string `json:"name"`
 		Floor        int    `json:"floor"`
 		BuildingName string `json:"building_name"`
+		// Optional schedule policy overriding the building's, nil keeps
+		// the room as it is
+		ScheduleRequired    *bool `json:"schedule_required"`
+		ScheduleGraceBefore *int  `json:"schedule_grace_before"`
+		ScheduleGraceAfter  *int  `json:"schedule_grace_after"`
+		// Only those with an access grant can borrow the keys of a restricted room
+		Restricted bool `json:"restricted"`
 	}
 	room_to_add := new(RoomToAdd)
 
@@ -131,6 +142,10 @@ func CreateRoom(c *fiber.Ctx) error {
 	room.Name = room_to_add.Name
 	room.Floor = room_to_add.Floor
 	room.BuildingID = storedBuilding.ID
+	room.ScheduleRequired = room_to_add.ScheduleRequired
+	room.ScheduleGraceBefore = room_to_add.ScheduleGraceBefore
+	room.ScheduleGraceAfter = room_to_add.ScheduleGraceAfter
+	room.Restricted = room_to_add.Restricted
 
 	// Create the Room
 	err = db.Create(&room).Error
@@ -177,6 +192,10 @@ func GetRoom(c *fiber.Ctx) error {
 // @Param name body string true "name"
 // @Param floor body int true "floor"
 // @Param building body Building true "building"
+// @Param schedule_required body bool false "schedule_required"
+// @Param schedule_grace_before body int false "schedule_grace_before"
+// @Param schedule_grace_after body int false "schedule_grace_after"
+// @Param restricted body bool false "restricted"
 // @Success 200 {object} model.Room
 // @router /api/room/{name} [put]
 func UpdateRoom(c *fiber.Ctx) error {
@@ -185,6 +204,14 @@ func UpdateRoom(c *fiber.Ctx) error {
 		Name     string         `json:"name"`
 		Floor    int            `json:"floor"`
 		Building model.Building `json:"building"`
+		// Optional schedule policy overriding the building's, nil keeps
+		// the room as it is
+		ScheduleRequired    *bool `json:"schedule_required"`
+		ScheduleGraceBefore *int  `json:"schedule_grace_before"`
+		ScheduleGraceAfter  *int  `json:"schedule_grace_after"`
+		// Only those with an access grant can borrow the keys of a restricted
+		// room, nil keeps the room as it is
+		Restricted *bool `json:"restricted"`
 	}
 
 	db := database.DB
@@ -213,6 +240,18 @@ func UpdateRoom(c *fiber.Ctx) error {
 	// Edit the room
 	room.Name = updateRoomData.Name
 	room.Floor = updateRoomData.Floor
+	if updateRoomData.ScheduleRequired != nil {
+		room.ScheduleRequired = updateRoomData.ScheduleRequired
+	}
+	if updateRoomData.ScheduleGraceBefore != nil {
+		room.ScheduleGraceBefore = updateRoomData.ScheduleGraceBefore
+	}
+	if updateRoomData.ScheduleGraceAfter != nil {
+		room.ScheduleGraceAfter = updateRoomData.ScheduleGraceAfter
+	}
+	if updateRoomData.Restricted != nil {
+		room.Restricted = *updateRoomData.Restricted
+	}
 
 	// Save the Changes
 	db.Save(&room)
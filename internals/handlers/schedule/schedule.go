@@ -42,6 +42,8 @@ func CreateSchedule(c *fiber.Ctx) error {
 		DayOfWeek      string `json:"day"`
 		Subject        string `json:"subject"`
 		InstructorName string `json:"instructor"`
+		Course         string `json:"course"`
+		Section        string `json:"section"`
 	}
 	var reqBody ScheduleToAdd
 
@@ -62,6 +64,8 @@ func CreateSchedule(c *fiber.Ctx) error {
 		DayOfWeek:      reqBody.DayOfWeek,
 		Subject:        reqBody.Subject,
 		InstructorName: reqBody.InstructorName,
+		Course:         reqBody.Course,
+		Section:        reqBody.Section,
 	}
 	newSchedule.ID = uuid.New()
 
@@ -84,6 +88,93 @@ func CreateSchedule(c *fiber.Ctx) error {
 	})
 }
 
+// UpdateSchedule update a schedule by id
+// @Description Update a schedule by id, the fields left out are kept. The schedule borrow policy matches students by course and section.
+// @Tags Schedule
+// @Accept json
+// @Produce json
+// @Param room_name body string false "room_name"
+// @Param start_time body string false "start_time"
+// @Param end_time body string false "end_time"
+// @Param day body string false "day"
+// @Param subject body string false "subject"
+// @Param instructor body string false "instructor"
+// @Param course body string false "course"
+// @Param section body string false "section"
+// @Success 200 {object} model.Schedule
+// @router /api/schedule/{id} [put]
+func UpdateSchedule(c *fiber.Ctx) error {
+	// Create a struct for updating only writable values
+	type updateSchedule struct {
+		RoomName       *string `json:"room_name"`
+		StartTime      *string `json:"start_time"`
+		EndTime        *string `json:"end_time"`
+		DayOfWeek      *string `json:"day"`
+		Subject        *string `json:"subject"`
+		InstructorName *string `json:"instructor"`
+		Course         *string `json:"course"`
+		Section        *string `json:"section"`
+	}
+
+	db := database.DB
+	var schedule model.Schedule
+
+	// Read the param id
+	id := c.Params("id")
+
+	// Find the schedule with the given id param
+	db.Find(&schedule, "id = ?", id)
+
+	// If no such schedule, return an error
+	if schedule.ID == uuid.Nil {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Schedule not found", "data": nil})
+	}
+
+	// Store the body containing the updated data
+	var updateScheduleData updateSchedule
+	err := c.BodyParser(&updateScheduleData)
+
+	// Return parsing error if encountered
+	if err != nil {
+		return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Review your input", "data": err})
+	}
+
+	// Edit the schedule
+	if updateScheduleData.RoomName != nil {
+		schedule.RoomName = *updateScheduleData.RoomName
+	}
+	if updateScheduleData.StartTime != nil {
+		schedule.StartTime = *updateScheduleData.StartTime
+	}
+	if updateScheduleData.EndTime != nil {
+		schedule.EndTime = *updateScheduleData.EndTime
+	}
+	if updateScheduleData.DayOfWeek != nil {
+		schedule.DayOfWeek = *updateScheduleData.DayOfWeek
+	}
+	if updateScheduleData.Subject != nil {
+		schedule.Subject = *updateScheduleData.Subject
+	}
+	if updateScheduleData.InstructorName != nil {
+		schedule.InstructorName = *updateScheduleData.InstructorName
+	}
+	if updateScheduleData.Course != nil {
+		schedule.Course = *updateScheduleData.Course
+	}
+	if updateScheduleData.Section != nil {
+		schedule.Section = *updateScheduleData.Section
+	}
+
+	// Save the Changes
+	err = db.Save(&schedule).Error
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Could not update schedule", "data": nil})
+	}
+
+	// Return the updated schedule
+	return c.JSON(fiber.Map{"status": "success", "message": "Schedule Updated", "data": schedule})
+}
+
 func isValidTimeFormat(timeStr string) bool {
 	// Define the regular expression pattern for time in the format HH:MM:SS
 	pattern := `^([01]\d|2[0-3]):([0-5]\d):([0-5]\d)$`
@@ -0,0 +1,209 @@
+package staffHandler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/vincemoke66/keyper-api/database"
+	"github.com/vincemoke66/keyper-api/internals/model"
+)
+
+// GetAllStaff func gets all existing staff
+// @Description Get all existing staff
+// @Tags Staff
+// @Accept json
+// @Produce json
+// @Success 200 {array} model.Staff
+// @router /api/staff [get]
+func GetAllStaff(c *fiber.Ctx) error {
+	db := database.DB
+	var staff []model.Staff
+
+	// find all staff in the database
+	db.Find(&staff)
+
+	// If no staff is present return an error
+	if len(staff) == 0 {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "No Staff data found", "data": nil})
+	}
+
+	// Return staff
+	return c.JSON(fiber.Map{"status": "success", "message": "Staff Found", "data": staff})
+}
+
+// CreateStaff func create a staff member
+// @Description Create a Staff member
+// @Tags Staff
+// @Accept json
+// @Produce json
+// @Param first_name body string true "first_name"
+// @Param last_name body string true "last_name"
+// @Param school_id body string true "school_id"
+// @Param rfid body string true "rfid"
+// @Param position body string true "position"
+// @Param master_key_access body bool false "master_key_access"
+// @Success 200 {object} model.Staff
+// @router /api/staff [post]
+func CreateStaff(c *fiber.Ctx) error {
+	db := database.DB
+	staff := new(model.Staff)
+
+	// Parse the body to the staff object
+	err := c.BodyParser(staff)
+	// Return parse error if any
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Review your input", "data": err})
+	}
+	// Return invalid school_id if empty or null
+	if staff.SchoolID == uuid.Nil.String() || staff.SchoolID == "" {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Invalid School ID", "data": err})
+	}
+
+	// Create a temporary staff data
+	var storedStaff model.Staff
+
+	// Find the staff member with the given school_id
+	db.Find(&storedStaff, "school_id = ?", staff.SchoolID)
+
+	// If staff school id exists, return an error
+	if storedStaff.ID != uuid.Nil {
+		return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Staff with the same school id already exist.", "data": nil})
+	}
+
+	// Find the staff member with the given rfid
+	if staff.RFID != "" {
+		db.Find(&storedStaff, "rfid = ?", staff.RFID)
+		// If staff rfid exists, return an error
+		if storedStaff.ID != uuid.Nil {
+			return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Staff with the same rfid already exist.", "data": nil})
+		}
+	}
+
+	// Add a uuid to the new staff member
+	staff.ID = uuid.New()
+
+	// Create the Staff and return error if encountered
+	err = db.Create(&staff).Error
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Could not create staff", "data": err})
+	}
+
+	// Return the created staff member
+	return c.JSON(fiber.Map{"status": "success", "message": "Staff created", "data": staff})
+}
+
+// GetStaff func get one staff member by school_id
+// @Description Get one staff member by school_id
+// @Tags Staff
+// @Accept json
+// @Produce json
+// @Success 200 {object} model.Staff
+// @router /api/staff/{school_id} [get]
+func GetStaff(c *fiber.Ctx) error {
+	db := database.DB
+	var staff model.Staff
+
+	// Read the param school_id
+	school_id := c.Params("school_id")
+
+	// Find the staff member with the given school_id
+	db.Find(&staff, "school_id = ?", school_id)
+
+	// If no such staff member present, return an error
+	if staff.ID == uuid.Nil {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Staff not found", "data": nil})
+	}
+
+	// Return the staff member with the specified school_id
+	return c.JSON(fiber.Map{"status": "success", "message": "Staff Found", "data": staff})
+}
+
+// UpdateStaff update a staff member by school_id
+// @Description Update a Staff member by school_id
+// @Tags Staff
+// @Accept json
+// @Produce json
+// @Param first_name body string true "first_name"
+// @Param last_name body string true "last_name"
+// @Param position body string true "position"
+// @Param master_key_access body bool false "master_key_access"
+// @Success 200 {object} model.Staff
+// @router /api/staff/{school_id} [put]
+func UpdateStaff(c *fiber.Ctx) error {
+	// Create a struct for updating only writable values
+	type updateStaff struct {
+		FirstName string `json:"first_name"`
+		LastName  string `json:"last_name"`
+		Position  string `json:"position"`
+		// MasterKeyAccess lets the staff borrow master keys
+		MasterKeyAccess bool `json:"master_key_access"`
+	}
+
+	db := database.DB
+	var staff model.Staff
+
+	// Read the param school_id
+	school_id := c.Params("school_id")
+
+	// Find the staff member with the given school_id param
+	db.Find(&staff, "school_id = ?", school_id)
+
+	// If no such staff member, return an error
+	if staff.ID == uuid.Nil {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Staff not found", "data": nil})
+	}
+
+	// Store the body containing the updated data
+	var updateStaffData updateStaff
+	err := c.BodyParser(&updateStaffData)
+
+	// Return parsing error if encountered
+	if err != nil {
+		return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Review your input", "data": err})
+	}
+
+	// Edit the staff member
+	staff.FirstName = updateStaffData.FirstName
+	staff.LastName = updateStaffData.LastName
+	staff.Position = updateStaffData.Position
+	staff.MasterKeyAccess = updateStaffData.MasterKeyAccess
+
+	// Save the Changes
+	db.Save(&staff)
+
+	// Return the updated staff member
+	return c.JSON(fiber.Map{"status": "success", "message": "Staff Updated", "data": staff})
+}
+
+// DeleteStaff delete a staff member by school_id
+// @Description Delete a Staff member by school_id
+// @Tags Staff
+// @Accept json
+// @Produce json
+// @Success 200
+// @router /api/staff/{school_id} [delete]
+func DeleteStaff(c *fiber.Ctx) error {
+	db := database.DB
+	var staff model.Staff
+
+	// Read the param school_id
+	school_id := c.Params("school_id")
+
+	// Find the staff member with the given school_id param
+	db.Find(&staff, "school_id = ?", school_id)
+
+	// If no such staff member present return an error
+	if staff.ID == uuid.Nil {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Staff not found", "data": nil})
+	}
+
+	// Delete the staff member
+	err := db.Delete(&staff, "school_id = ?", school_id).Error
+
+	// Return error if encountered
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to delete staff", "data": nil})
+	}
+
+	// Return success message
+	return c.JSON(fiber.Map{"status": "success", "message": "Staff Deleted"})
+}
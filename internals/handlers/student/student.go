@@ -138,6 +138,68 @@ func GetStudentThroughRFID(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"status": "success", "message": "Student Found", "data": student})
 }
 
+// GetStudentKeys func gets the keys a student currently holds by school_id
+// @Description Get the keys a student currently holds by school_id
+// @Tags Student
+// @Accept json
+// @Produce json
+// @Success 200 {array} model.Key
+// @router /api/student/school_id/{school_id}/keys [get]
+func GetStudentKeys(c *fiber.Ctx) error {
+	db := database.DB
+	var student model.Student
+
+	// Read the param school_id
+	school_id := c.Params("school_id")
+
+	// Find the student with the given school_id
+	db.Find(&student, "school_id = ?", school_id)
+
+	return heldKeys(c, student)
+}
+
+// GetStudentKeysThroughRFID func gets the keys a student currently holds by rfid
+// @Description Get the keys a student currently holds by rfid
+// @Tags Student
+// @Accept json
+// @Produce json
+// @Success 200 {array} model.Key
+// @router /api/student/rfid/{rfid}/keys [get]
+func GetStudentKeysThroughRFID(c *fiber.Ctx) error {
+	db := database.DB
+	var student model.Student
+
+	// Read the param rfid
+	rfid := c.Params("rfid")
+
+	// Find the student with the given rfid
+	db.Find(&student, "rfid = ?", rfid)
+
+	return heldKeys(c, student)
+}
+
+// heldKeys responds with the keys the student currently holds
+func heldKeys(c *fiber.Ctx, student model.Student) error {
+	db := database.DB
+	var keys []model.Key
+
+	// If no such student present, return an error
+	if student.ID == uuid.Nil {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Student not found", "data": nil})
+	}
+
+	// find all keys held by the student
+	db.Order("borrowed_at ASC").Find(&keys, "status = ? AND holder_type = ? AND holder_id = ?", model.KeyStatusBorrowed, model.HolderTypeStudent, student.ID)
+
+	// If the student holds no key return an error
+	if len(keys) == 0 {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Student holds no keys", "data": nil})
+	}
+
+	// Return the held keys
+	return c.JSON(fiber.Map{"status": "success", "message": "Keys Found", "data": keys})
+}
+
 // UpdateStudent update a student by school_id
 // @Description Update a Student by school_id
 // @Tags Student
@@ -0,0 +1,220 @@
+package suspensionHandler
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/vincemoke66/keyper-api/database"
+	recordHandler "github.com/vincemoke66/keyper-api/internals/handlers/record"
+	"github.com/vincemoke66/keyper-api/internals/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetSuspensions func gets all suspensions
+// @Description Get all suspensions, newest first, optionally only the active ones of a student
+// @Tags Suspension
+// @Accept json
+// @Produce json
+// @Param school_id query string false "school_id"
+// @Param active query bool false "active"
+// @Success 200 {array} model.Suspension
+// @router /api/suspension [get]
+func GetSuspensions(c *fiber.Ctx) error {
+	db := database.DB
+	var suspensions []model.Suspension
+
+	query := db.Order("starts_at DESC")
+	if school_id := c.Query("school_id"); school_id != "" {
+		query = query.Where("school_id = ?", school_id)
+	}
+	if c.QueryBool("active") {
+		query = recordHandler.ActiveSuspensions(query, time.Now())
+	}
+
+	// find all suspensions in the database
+	query.Find(&suspensions)
+
+	// If no suspension is present return an error
+	if len(suspensions) == 0 {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "No Suspensions data found", "data": nil})
+	}
+
+	// Return suspensions
+	return c.JSON(fiber.Map{"status": "success", "message": "Suspensions Found", "data": suspensions})
+}
+
+// GetSuspension func get one suspension by id
+// @Description Get one suspension by id along with its audit trail
+// @Tags Suspension
+// @Accept json
+// @Produce json
+// @Success 200 {object} model.Suspension
+// @router /api/suspension/{id} [get]
+func GetSuspension(c *fiber.Ctx) error {
+	db := database.DB
+	var suspension model.Suspension
+
+	// Read the param id
+	id := c.Params("id")
+
+	// Find the suspension with the given id
+	db.Find(&suspension, "id = ?", id)
+
+	// If no such suspension present, return an error
+	if suspension.ID == uuid.Nil {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Suspension not found", "data": nil})
+	}
+
+	// Find the audit trail of the suspension
+	var events []model.SuspensionEvent
+	db.Order("created_at").Find(&events, "suspension_id = ?", suspension.ID)
+
+	// Return the suspension with the specified id
+	return c.JSON(fiber.Map{"status": "success", "message": "Suspension Found", "data": fiber.Map{"suspension": suspension, "events": events}})
+}
+
+// CreateSuspension func suspends a student from borrowing
+// @Description Suspends a student from borrowing until ends_at, or until lifted when it is empty
+// @Tags Suspension
+// @Accept json
+// @Produce json
+// @Param school_id body string true "school_id"
+// @Param reason body string true "reason"
+// @Param ends_at body string false "ends_at"
+// @Param created_by body string true "created_by"
+// @Success 200 {object} model.Suspension
+// @router /api/suspension [post]
+func CreateSuspension(c *fiber.Ctx) error {
+	db := database.DB
+	suspension := new(model.Suspension)
+
+	type SuspensionToAdd struct {
+		SchoolID  string `json:"school_id"`
+		Reason    string `json:"reason"`
+		EndsAt    string `json:"ends_at"`
+		CreatedBy string `json:"created_by"`
+	}
+
+	suspension_to_add := new(SuspensionToAdd)
+
+	// Parse the body to the suspension object
+	err := c.BodyParser(suspension_to_add)
+	// Return parse error if any
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Review your input", "data": err})
+	}
+	if suspension_to_add.SchoolID == "" || suspension_to_add.Reason == "" || suspension_to_add.CreatedBy == "" {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Review your input", "data": nil})
+	}
+
+	suspension.StartsAt = time.Now()
+
+	// Parse the end of the suspension if one was given
+	if suspension_to_add.EndsAt != "" {
+		endsAt, err := time.Parse(time.RFC3339, suspension_to_add.EndsAt)
+		if err != nil || !endsAt.After(suspension.StartsAt) {
+			return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Invalid ends_at", "data": nil})
+		}
+		suspension.EndsAt = &endsAt
+	}
+
+	// Create a temporary student data
+	var storedStudent model.Student
+	db.Find(&storedStudent, "school_id = ?", suspension_to_add.SchoolID)
+	// If student does not exist, return an error
+	if storedStudent.ID == uuid.Nil {
+		return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Student does not exist.", "data": nil})
+	}
+
+	suspension.StudentID = storedStudent.ID
+	suspension.SchoolID = storedStudent.SchoolID
+	suspension.StudentName = storedStudent.LastName + ", " + storedStudent.FirstName
+	suspension.Source = model.SuspensionSourceManual
+	suspension.Reason = suspension_to_add.Reason
+	suspension.CreatedBy = suspension_to_add.CreatedBy
+
+	// Create the Suspension and return error if encountered
+	err = db.Transaction(func(tx *gorm.DB) error {
+		return recordHandler.Suspend(tx, suspension)
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Could not create suspension", "data": err})
+	}
+
+	// Return the created suspension
+	return c.JSON(fiber.Map{"status": "success", "message": "Suspension created", "data": suspension})
+}
+
+// LiftSuspension func lifts a suspension by id
+// @Description Lifts a suspension so the student can borrow again
+// @Tags Suspension
+// @Accept json
+// @Produce json
+// @Param reason body string true "reason"
+// @Param lifted_by body string true "lifted_by"
+// @Success 200 {object} model.Suspension
+// @router /api/suspension/{id}/lift [post]
+func LiftSuspension(c *fiber.Ctx) error {
+	db := database.DB
+
+	type Lift struct {
+		Reason   string `json:"reason"`
+		LiftedBy string `json:"lifted_by"`
+	}
+
+	lift := new(Lift)
+
+	// Parse the body to the lift object
+	err := c.BodyParser(lift)
+	// Return parse error if any
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Review your input", "data": err})
+	}
+	if lift.Reason == "" || lift.LiftedBy == "" {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Review your input", "data": nil})
+	}
+
+	// Read the param id
+	id := c.Params("id")
+
+	var suspension model.Suspension
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Find and lock the suspension with the given id
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Find(&suspension, "id = ?", id).Error
+		if err != nil {
+			return err
+		}
+		if suspension.ID == uuid.Nil {
+			return &recordHandler.RecordError{Status: 404, Message: "Suspension not found"}
+		}
+
+		// A suspension is only lifted once
+		if suspension.LiftedAt != nil {
+			return &recordHandler.RecordError{Status: 409, Message: "Suspension already lifted"}
+		}
+
+		now := time.Now()
+		suspension.LiftedAt = &now
+		suspension.LiftedBy = lift.LiftedBy
+		err = tx.Save(&suspension).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Create(&model.SuspensionEvent{
+			ID:           uuid.New(),
+			SuspensionID: suspension.ID,
+			Action:       model.SuspensionEventLifted,
+			By:           lift.LiftedBy,
+			Reason:       lift.Reason,
+		}).Error
+	})
+	if err != nil {
+		return recordHandler.RespondErrorWith(c, err, "Could not lift suspension")
+	}
+
+	// Return the lifted suspension
+	return c.JSON(fiber.Map{"status": "success", "message": "Suspension lifted", "data": suspension})
+}
@@ -0,0 +1,107 @@
+package tapHandler
+
+import (
+	"sync"
+	"time"
+
+	recordHandler "github.com/vincemoke66/keyper-api/internals/handlers/record"
+)
+
+// TapSession is opened on a reader by a card tap and completed by the next key tap
+type TapSession struct {
+	DeviceID  string                 `json:"device_id"`
+	Borrower  recordHandler.Borrower `json:"borrower"`
+	OpenedAt  time.Time              `json:"opened_at"`
+	ExpiresAt time.Time              `json:"expires_at"`
+	// claimed while a key tap uses the session
+	claimed bool
+}
+
+// Expired sessions are kept a while longer so that a late key tap can be told
+// its session expired instead of being treated as a tap without a card
+const expiredSessionRetention = 10 * time.Minute
+
+// sessionStore keeps the open session of each reader in memory
+type sessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]TapSession
+}
+
+var sessions = &sessionStore{sessions: map[string]TapSession{}}
+
+// open replaces the session of the reader with a new one for the borrower
+func (s *sessionStore) open(deviceID string, borrower recordHandler.Borrower, ttl time.Duration) TapSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.purge(now)
+
+	session := TapSession{DeviceID: deviceID, Borrower: borrower, OpenedAt: now, ExpiresAt: now.Add(ttl)}
+	s.sessions[deviceID] = session
+	return session
+}
+
+// claim returns the session of the reader, if any, keeping it open until the
+// key tap using it is committed or rolled back. A session claimed by another
+// key tap is refused.
+func (s *sessionStore) claim(deviceID string) (TapSession, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purge(time.Now())
+
+	session, found := s.sessions[deviceID]
+	if !found {
+		return session, false, nil
+	}
+	if session.claimed {
+		return session, true, &recordHandler.RecordError{Status: 409, Code: "SESSION_BUSY", Message: "Another key is being tapped, wait a moment"}
+	}
+	session.claimed = true
+	s.sessions[deviceID] = session
+	return session, true, nil
+}
+
+// release reopens a claimed session whose key tap was rolled back
+func (s *sessionStore) release(session TapSession) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stored, found := s.sessions[session.DeviceID]; found && stored.OpenedAt.Equal(session.OpenedAt) {
+		stored.claimed = false
+		s.sessions[session.DeviceID] = stored
+	}
+}
+
+// take removes and returns the session of the reader, if any
+func (s *sessionStore) take(deviceID string) (TapSession, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purge(time.Now())
+
+	session, found := s.sessions[deviceID]
+	delete(s.sessions, deviceID)
+	return session, found
+}
+
+// close removes the session of the reader when it is still the given one, a
+// card tapped since then keeps its own session
+func (s *sessionStore) close(session TapSession) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stored, found := s.sessions[session.DeviceID]; found && stored.OpenedAt.Equal(session.OpenedAt) {
+		delete(s.sessions, session.DeviceID)
+	}
+}
+
+// purge forgets the sessions that expired long ago
+func (s *sessionStore) purge(now time.Time) {
+	for deviceID, session := range s.sessions {
+		if now.Sub(session.ExpiresAt) > expiredSessionRetention {
+			delete(s.sessions, deviceID)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package tapHandler
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/vincemoke66/keyper-api/config"
+	"github.com/vincemoke66/keyper-api/database"
+	recordHandler "github.com/vincemoke66/keyper-api/internals/handlers/record"
+	"github.com/vincemoke66/keyper-api/internals/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CreateTap func borrows or returns a key depending on its current state
+// @Description Borrows the key when it is available and returns it when it is borrowed. Borrowing needs the card rfid or the school_id of the borrower.
+// @Tags Tap
+// @Accept json
+// @Produce json
+// @Param key_rfid body string true "key_rfid"
+// @Param card_rfid body string false "card_rfid"
+// @Param school_id body string false "school_id"
+// @Param borrower_type body string false "borrower_type"
+// @Param due_at body string false "due_at"
+// @Param cabinet body string false "cabinet"
+// @Success 200 {object} TapResult
+// @router /api/tap [post]
+func CreateTap(c *fiber.Ctx) error {
+	db := database.DB
+
+	type TapToAdd struct {
+		KeyRFID      string           `json:"key_rfid"`
+		CardRFID     string           `json:"card_rfid"`
+		SchoolID     string           `json:"school_id"`
+		BorrowerType model.HolderType `json:"borrower_type"`
+		DueAt        string           `json:"due_at"`
+		Cabinet      string           `json:"cabinet"`
+	}
+
+	tap_to_add := new(TapToAdd)
+
+	// Parse the body to the tap object
+	err := c.BodyParser(tap_to_add)
+	// Return parse error if any
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Error parsing data", "data": err})
+	}
+	if tap_to_add.KeyRFID == "" {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Review your input", "data": nil})
+	}
+
+	// Infer the action and apply it in a single transaction
+	var record model.Record
+	err = db.Transaction(func(tx *gorm.DB) error {
+		record_to_add := recordHandler.RecordToAdd{
+			BorrowerType: tap_to_add.BorrowerType,
+			SchoolID:     tap_to_add.SchoolID,
+			RFID:         tap_to_add.KeyRFID,
+			DueAt:        tap_to_add.DueAt,
+			Cabinet:      tap_to_add.Cabinet,
+		}
+
+		// The card tells who is tapping, whatever kind of borrower they are
+		if tap_to_add.CardRFID != "" {
+			borrower, err := recordHandler.FindBorrowerByRFID(tx, tap_to_add.CardRFID)
+			if err != nil {
+				return err
+			}
+			record_to_add.BorrowerType = borrower.Type
+			record_to_add.SchoolID = borrower.SchoolID
+		}
+
+		var err error
+		record, err = TapKey(tx, record_to_add)
+		return err
+	})
+	if err != nil {
+		return recordHandler.RespondError(c, err)
+	}
+
+	// Return what the kiosk should display
+	result := NewTapResult(record)
+	return c.JSON(fiber.Map{"status": "success", "message": result.Display, "data": result})
+}
+
+// CreateReaderTap func handles a tag tapped on a reader
+// @Description A card tap opens a session on the reader, the next key tap within TAP_SESSION_TTL borrows or returns the key for the card holder. A key tap without a session can only return the key.
+// @Tags Tap
+// @Accept json
+// @Produce json
+// @Param rfid body string true "rfid"
+// @Success 200 {object} ReaderTap
+// @router /api/tap/device/{device_id} [post]
+func CreateReaderTap(c *fiber.Ctx) error {
+	db := database.DB
+
+	type TagToTap struct {
+		RFID string `json:"rfid"`
+	}
+
+	tag_to_tap := new(TagToTap)
+
+	// Parse the body to the tag object
+	err := c.BodyParser(tag_to_tap)
+	// Return parse error if any
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Error parsing data", "data": err})
+	}
+	if tag_to_tap.RFID == "" {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Review your input", "data": nil})
+	}
+
+	// Read the param device_id
+	device_id := c.Params("device_id")
+
+	var (
+		readerTap ReaderTap
+		// The card holder a session is opened for once the tap is committed
+		cardHolder *recordHandler.Borrower
+		// The session the tap used, closed once the tap is committed
+		usedSession *TapSession
+	)
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Find and lock the key with the given rfid
+		var storedKey model.Key
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Find(&storedKey, "rfid = ?", tag_to_tap.RFID).Error
+		if err != nil {
+			return err
+		}
+
+		// Any other tag has to be a card, which opens a session
+		if storedKey.ID == uuid.Nil {
+			borrower, err := recordHandler.FindBorrowerByRFID(tx, tag_to_tap.RFID)
+			if _, ok := err.(*recordHandler.RecordError); ok {
+				return &recordHandler.RecordError{Status: 404, Code: "UNKNOWN_TAG", Message: "Tag is not registered"}
+			}
+			if err != nil {
+				return err
+			}
+
+			cardHolder = &borrower
+			return nil
+		}
+
+		record_to_add := recordHandler.RecordToAdd{RFID: storedKey.RFID}
+
+		// Readers mounted on a cabinet take and return keys at that cabinet
+		var storedCabinet model.Cabinet
+		tx.Find(&storedCabinet, "reader_id = ?", device_id)
+		record_to_add.Cabinet = storedCabinet.Name
+
+		// The session opened by the card says who is taking or returning the
+		// key, it stays open until the tap is committed
+		session, found, err := sessions.claim(device_id)
+		if err != nil {
+			return err
+		}
+		if found {
+			usedSession = &session
+			if time.Now().After(session.ExpiresAt) {
+				return &recordHandler.RecordError{Status: 409, Code: "SESSION_EXPIRED", Message: "Session expired, tap your card again"}
+			}
+			if storedKey.Status == model.KeyStatusBorrowed && (storedKey.HolderType != session.Borrower.Type || storedKey.HolderID != session.Borrower.ID) {
+				return &recordHandler.RecordError{Status: 409, Code: "SESSION_MISMATCH", Message: "Key is held by " + storedKey.HolderName + ", not " + session.Borrower.Name}
+			}
+			record_to_add.BorrowerType = session.Borrower.Type
+			record_to_add.SchoolID = session.Borrower.SchoolID
+		}
+
+		record, err := TapKey(tx, record_to_add)
+		if err != nil {
+			return err
+		}
+
+		result := NewTapResult(record)
+		readerTap = ReaderTap{State: ReaderStateCompleted, Display: result.Display, Result: &result}
+		return nil
+	})
+
+	// The session is closed once its key tap is committed, an expired one is
+	// told once and the next key tap has no session
+	if usedSession != nil {
+		if err == nil || time.Now().After(usedSession.ExpiresAt) {
+			sessions.close(*usedSession)
+		} else {
+			sessions.release(*usedSession)
+		}
+	}
+	if err != nil {
+		return recordHandler.RespondError(c, err)
+	}
+
+	// The card opens its session only once nothing can roll it back
+	if cardHolder != nil {
+		session := sessions.open(device_id, *cardHolder, config.Duration("TAP_SESSION_TTL", 15*time.Second))
+		readerTap = ReaderTap{State: ReaderStateSessionOpened, Display: "Hello " + cardHolder.Name + ", tap the key", Session: &session}
+	}
+
+	// Return what the reader should display
+	return c.JSON(fiber.Map{"status": "success", "message": readerTap.Display, "data": readerTap})
+}
+
+// CancelReaderSession func cancels the open session of a reader
+// @Description Cancels the session opened on a reader by a card tap
+// @Tags Tap
+// @Accept json
+// @Produce json
+// @Success 200
+// @router /api/tap/device/{device_id} [delete]
+func CancelReaderSession(c *fiber.Ctx) error {
+	// Read the param device_id
+	device_id := c.Params("device_id")
+
+	// If the reader has no session, return an error
+	session, found := sessions.take(device_id)
+	if !found || time.Now().After(session.ExpiresAt) {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Session not found", "data": nil})
+	}
+
+	// Return success message
+	return c.JSON(fiber.Map{"status": "success", "message": "Session Cancelled"})
+}
+
+// ReaderTap is what a reader shows after a tag is tapped on it
+type ReaderTap struct {
+	State   ReaderState `json:"state"`
+	Display string      `json:"display"`
+	Session *TapSession `json:"session,omitempty"`
+	Result  *TapResult  `json:"result,omitempty"`
+}
+
+type ReaderState string
+
+const (
+	ReaderStateSessionOpened ReaderState = "session_opened"
+	ReaderStateCompleted     ReaderState = "completed"
+)
+
+// TapKey fills in the type of record_to_add from the current state of its key,
+// a return when the key is borrowed and a borrow otherwise, then processes it.
+// The key stays locked until tx ends.
+func TapKey(tx *gorm.DB, record_to_add recordHandler.RecordToAdd) (model.Record, error) {
+	// Find and lock the key with the given rfid
+	var storedKey model.Key
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Find(&storedKey, "rfid = ?", record_to_add.RFID).Error
+	if err != nil {
+		return model.Record{}, err
+	}
+	// If key does not exists, return an error
+	if storedKey.ID == uuid.Nil {
+		return model.Record{}, &recordHandler.RecordError{Status: 409, Message: "Key does not exist."}
+	}
+
+	record_to_add.Type = model.RecordTypeBorrow
+	if storedKey.Status == model.KeyStatusBorrowed {
+		record_to_add.Type = model.RecordTypeReturn
+	}
+
+	// Nobody can borrow without saying who they are
+	if record_to_add.Type == model.RecordTypeBorrow && record_to_add.SchoolID == "" && storedKey.Status.CanTransitionTo(model.KeyStatusBorrowed) {
+		return model.Record{}, &recordHandler.RecordError{Status: 400, Code: "CARD_REQUIRED", Message: "Tap your card to borrow the key"}
+	}
+
+	return recordHandler.ProcessRecord(tx, record_to_add)
+}
+
+// TapResult is what a kiosk shows after a tap
+type TapResult struct {
+	Action       model.RecordType `json:"action"`
+	Name         string           `json:"name"`
+	RoomName     string           `json:"room_name"`
+	BuildingName string           `json:"building_name"`
+	DueAt        *time.Time       `json:"due_at"`
+	Late         bool             `json:"late"`
+	Display      string           `json:"display"`
+	Record       model.Record     `json:"record"`
+}
+
+// NewTapResult describes the record written for a tap
+func NewTapResult(record model.Record) TapResult {
+	result := TapResult{
+		Action:       record.Type,
+		Name:         record.BorrowerName,
+		RoomName:     record.RoomName,
+		BuildingName: record.BuildingName,
+		DueAt:        record.DueAt,
+		Late:         record.Late,
+		Record:       record,
+	}
+
+	switch {
+	case record.Type == model.RecordTypeBorrow && record.DueAt != nil:
+		result.Display = "Borrowed " + record.RoomName + ", return by " + record.DueAt.Local().Format("15:04")
+	case record.Type == model.RecordTypeBorrow:
+		result.Display = "Borrowed " + record.RoomName
+	case record.Late:
+		result.Display = "Returned " + record.RoomName + " late"
+	default:
+		result.Display = "Returned " + record.RoomName
+	}
+
+	return result
+}
@@ -0,0 +1,23 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"github.com/vincemoke66/keyper-api/database"
+	"github.com/vincemoke66/keyper-api/internals/model"
+)
+
+// PurgeIdempotentResponses deletes the stored responses whose
+// IDEMPOTENCY_WINDOW has passed
+func PurgeIdempotentResponses() {
+	result := database.DB.Where("expires_at <= ?", time.Now()).Delete(&model.IdempotentResponse{})
+	if result.Error != nil {
+		log.Println("Failed to purge idempotent responses:", result.Error)
+		return
+	}
+
+	if result.RowsAffected > 0 {
+		log.Printf("Purged %d idempotent response(s)", result.RowsAffected)
+	}
+}
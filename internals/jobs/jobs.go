@@ -0,0 +1,24 @@
+package jobs
+
+import (
+	"time"
+
+	"github.com/vincemoke66/keyper-api/config"
+)
+
+// Start runs the background jobs of the api
+func Start() {
+	go every(config.Duration("OVERDUE_SWEEP_INTERVAL", time.Minute), SweepOverdueKeys)
+	go every(config.Duration("WAITLIST_SWEEP_INTERVAL", 15*time.Second), ExpireWaitlistHolds)
+	go every(time.Hour, PurgeIdempotentResponses)
+}
+
+// every calls job once per interval for as long as the process lives
+func every(interval time.Duration, job func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		job()
+	}
+}
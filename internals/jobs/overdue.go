@@ -0,0 +1,26 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"github.com/vincemoke66/keyper-api/database"
+	"github.com/vincemoke66/keyper-api/internals/model"
+)
+
+// SweepOverdueKeys flags every borrowed key that is past its due time
+func SweepOverdueKeys() {
+	db := database.DB
+
+	result := db.Model(&model.Key{}).
+		Where("status = ? AND overdue = ? AND due_at < ?", model.KeyStatusBorrowed, false, time.Now()).
+		Update("overdue", true)
+	if result.Error != nil {
+		log.Println("Failed to sweep overdue keys:", result.Error)
+		return
+	}
+
+	if result.RowsAffected > 0 {
+		log.Printf("Flagged %d overdue key(s)", result.RowsAffected)
+	}
+}
@@ -0,0 +1,51 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"github.com/vincemoke66/keyper-api/database"
+	recordHandler "github.com/vincemoke66/keyper-api/internals/handlers/record"
+	"github.com/vincemoke66/keyper-api/internals/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ExpireWaitlistHolds passes every key whose hold ran out to the next one in
+// its waitlist, then sends the notices of the new holds
+func ExpireWaitlistHolds() {
+	db := database.DB
+
+	var holds []model.WaitlistEntry
+	db.Find(&holds, "status = ? AND hold_until <= ?", model.WaitlistStatusHolding, time.Now())
+
+	expired := 0
+	for _, hold := range holds {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// Lock the key so that it is not borrowed while the hold is passed on
+			var key model.Key
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Find(&key, "id = ?", hold.KeyID).Error
+			if err != nil {
+				return err
+			}
+
+			// The key may have been taken since
+			result := tx.Model(&model.WaitlistEntry{}).Where("id = ? AND status = ?", hold.ID, model.WaitlistStatusHolding).Update("status", model.WaitlistStatusExpired)
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			expired++
+
+			return recordHandler.OfferKey(tx, key)
+		})
+		if err != nil {
+			log.Println("Failed to expire waitlist hold:", err)
+		}
+	}
+
+	if expired > 0 {
+		log.Printf("Expired %d waitlist hold(s)", expired)
+	}
+
+	recordHandler.SendHoldNotices(db)
+}
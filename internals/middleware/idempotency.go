@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"database/sql"
+	"log"
+	"os"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/idempotency"
+	"github.com/vincemoke66/keyper-api/config"
+	"github.com/vincemoke66/keyper-api/database"
+)
+
+// scopedKeyHeader carries the Idempotency-Key scoped to the method and path
+// of the request, so that the same key sent to two endpoints is not mixed up
+const scopedKeyHeader = "X-Scoped-Idempotency-Key"
+
+// Idempotency replays the stored response of a create request when it is
+// retried with the same Idempotency-Key header on the same endpoint, so
+// reader retries have no side effects. Responses are kept for IDEMPOTENCY_WINDOW
+// in the database, or in memory when IDEMPOTENCY_STORAGE is memory.
+func Idempotency() fiber.Handler {
+	cfg := idempotency.Config{
+		// Only create requests are made idempotent
+		Next: func(c *fiber.Ctx) bool {
+			return c.Method() != fiber.MethodPost
+		},
+		Lifetime:  config.Duration("IDEMPOTENCY_WINDOW", 24*time.Hour),
+		KeyHeader: scopedKeyHeader,
+		// The key itself is validated before it is scoped
+		KeyHeaderValidate: func(key string) error {
+			return nil
+		},
+	}
+
+	// Responses kept in memory are lost on restart and not shared between
+	// instances of the api
+	if os.Getenv("IDEMPOTENCY_STORAGE") != "memory" {
+		sqlDB, err := database.DB.DB()
+		if err != nil {
+			log.Fatal("Failed to set up the idempotency storage: ", err)
+		}
+		cfg.Storage = &idempotencyStore{db: database.DB}
+		cfg.Lock = &idempotencyLock{db: sqlDB, conns: map[string]*sql.Conn{}}
+	}
+	replay := idempotency.New(cfg)
+
+	return func(c *fiber.Ctx) error {
+		// Never trust a scoped key sent by the client
+		c.Request().Header.Del(scopedKeyHeader)
+
+		if key := c.Get("Idempotency-Key"); key != "" {
+			if len(key) > 255 {
+				return fiber.NewError(fiber.StatusBadRequest, "Idempotency-Key is too long")
+			}
+			c.Request().Header.Set(scopedKeyHeader, c.Method()+" "+c.Path()+" "+key)
+		}
+
+		return replay(c)
+	}
+}
@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/vincemoke66/keyper-api/internals/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// idempotencyStore keeps the stored responses in the database, so that they
+// survive a restart and are shared by every instance of the api
+type idempotencyStore struct {
+	db *gorm.DB
+}
+
+func (s *idempotencyStore) Get(key string) ([]byte, error) {
+	var response model.IdempotentResponse
+	err := s.db.Limit(1).Find(&response, "key = ? AND (expires_at IS NULL OR expires_at > ?)", key, time.Now()).Error
+	if err != nil || response.Key == "" {
+		return nil, err
+	}
+	return response.Value, nil
+}
+
+func (s *idempotencyStore) Set(key string, val []byte, exp time.Duration) error {
+	if key == "" || len(val) == 0 {
+		return nil
+	}
+
+	response := model.IdempotentResponse{Key: key, Value: val}
+	if exp > 0 {
+		expiresAt := time.Now().Add(exp)
+		response.ExpiresAt = &expiresAt
+	}
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "expires_at"}),
+	}).Create(&response).Error
+}
+
+func (s *idempotencyStore) Delete(key string) error {
+	return s.db.Delete(&model.IdempotentResponse{}, "key = ?", key).Error
+}
+
+func (s *idempotencyStore) Reset() error {
+	return s.db.Where("1 = 1").Delete(&model.IdempotentResponse{}).Error
+}
+
+func (s *idempotencyStore) Close() error {
+	return nil
+}
+
+// idempotencyLock makes a request wait for another one with the same key on
+// any instance of the api. It takes a postgres advisory lock, which belongs to
+// a connection, so the connection is held until the key is unlocked.
+type idempotencyLock struct {
+	db    *sql.DB
+	mu    sync.Mutex
+	conns map[string]*sql.Conn
+}
+
+func (l *idempotencyLock) Lock(key string) error {
+	ctx := context.Background()
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock(hashtextextended($1, 0))", key)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	l.mu.Lock()
+	l.conns[key] = conn
+	l.mu.Unlock()
+	return nil
+}
+
+func (l *idempotencyLock) Unlock(key string) error {
+	l.mu.Lock()
+	conn, found := l.conns[key]
+	delete(l.conns, key)
+	l.mu.Unlock()
+	if !found {
+		return nil
+	}
+	defer conn.Close()
+
+	_, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock(hashtextextended($1, 0))", key)
+	return err
+}
@@ -1,6 +1,8 @@
 package model
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
@@ -10,6 +12,17 @@ type Building struct {
 	ID    uuid.UUID `gorm:"type:uuid"`
 	Name  string    `json:"name"`
 	Abbrv string    `json:"abbrv"`
+	// DefaultBorrowMinutes is how long a key of this building may be held
+	// when neither the request nor a schedule gives a due time
+	DefaultBorrowMinutes int `json:"default_borrow_minutes"`
+	// ScheduleRequired only lets students borrow a key when they have a
+	// schedule in its room, give or take the grace minutes
+	ScheduleRequired    bool `json:"schedule_required"`
+	ScheduleGraceBefore int  `json:"schedule_grace_before"`
+	ScheduleGraceAfter  int  `json:"schedule_grace_after"`
+	// Borrow limits of the building, zero follows the global limits
+	MaxKeysPerStudent         int `json:"max_keys_per_student"`
+	LateReturnCooldownMinutes int `json:"late_return_cooldown_minutes"`
 }
 
 type Room struct {
@@ -17,19 +30,82 @@ type Room struct {
 	ID         uuid.UUID `gorm:"type:uuid"`
 	Name       string    `json:"name"`
 	Floor      int       `json:"floor"`
-	BuildingID uuid.UUID `gorm:"foreignkey:BuildingID"`
+	BuildingID uuid.UUID `gorm:"type:uuid;foreignkey:BuildingID"`
+	// The schedule policy of the room, nil values follow the building
+	ScheduleRequired    *bool `json:"schedule_required"`
+	ScheduleGraceBefore *int  `json:"schedule_grace_before"`
+	ScheduleGraceAfter  *int  `json:"schedule_grace_after"`
+	// Restricted rooms only lend their keys to those with a RoomAccessGrant
+	Restricted bool `json:"restricted"`
+}
+
+// Cabinet is a key cabinet with its own reader
+type Cabinet struct {
+	gorm.Model
+	ID           uuid.UUID `gorm:"type:uuid"`
+	Name         string    `json:"name"`
+	BuildingID   uuid.UUID `json:"building_id" gorm:"type:uuid;foreignkey:BuildingID"`
+	BuildingName string    `json:"building_name"`
+	Location     string    `json:"location"`
+	// ReaderID is the device id of the reader on the cabinet
+	ReaderID string `json:"reader_id" gorm:"index"`
+	// ReturnAnywhere lets keys of other cabinets be returned to this one
+	ReturnAnywhere bool `json:"return_anywhere"`
 }
 
 type Key struct {
 	gorm.Model
 	ID           uuid.UUID `gorm:"type:uuid"`
-	RFID         string    `json:"rfid" gorm:"column:rfid"`
+	RFID         string    `json:"rfid" gorm:"column:rfid;index"`
 	Status       KeyStatus `json:"status"`
-	BuildingID   uuid.UUID `gorm:"foreignkey:BuildingID"`
-	RoomID       uuid.UUID `gorm:"foreignkey:RoomID"`
+	BuildingID   uuid.UUID `gorm:"type:uuid;foreignkey:BuildingID"`
+	RoomID       uuid.UUID `gorm:"type:uuid;foreignkey:RoomID"`
 	RoomName     string
 	RoomFloor    int `json:"floor"`
 	BuildingName string
+	DueAt        *time.Time `json:"due_at"`
+	Overdue      bool       `json:"overdue"`
+	KeySetID     uuid.UUID  `json:"key_set_id" gorm:"type:uuid;foreignkey:KeySetID"`
+	CopyNumber   int        `json:"copy_number"`
+	// ReplacesKeyID is the lost key this key was made to replace
+	ReplacesKeyID uuid.UUID `json:"replaces_key_id" gorm:"type:uuid"`
+	// The current holder of a borrowed key
+	HolderType     HolderType `json:"holder_type" gorm:"index:idx_keys_holder"`
+	HolderID       uuid.UUID  `json:"holder_id" gorm:"type:uuid;index:idx_keys_holder"`
+	HolderSchoolID string     `json:"holder_school_id"`
+	HolderName     string     `json:"holder_name"`
+	BorrowedAt     *time.Time `json:"borrowed_at"`
+	// LastRecordID is the latest record written for the key
+	LastRecordID uuid.UUID `json:"last_record_id" gorm:"type:uuid"`
+	// The cabinet the key belongs to, and the one it was last returned to
+	// when that is another cabinet that takes returns from anywhere
+	CabinetID        uuid.UUID `json:"cabinet_id" gorm:"type:uuid;index"`
+	CabinetName      string    `json:"cabinet_name"`
+	CurrentCabinetID uuid.UUID `json:"current_cabinet_id" gorm:"type:uuid;index"`
+	// AccessGroupID makes the key a master key opening every room of the
+	// group, RoomName is then the name of the group
+	AccessGroupID uuid.UUID `json:"access_group_id" gorm:"type:uuid;index"`
+}
+
+// ClearHolder empties the custody fields of a key that is no longer borrowed
+func (k *Key) ClearHolder() {
+	k.HolderType = ""
+	k.HolderID = uuid.Nil
+	k.HolderSchoolID = ""
+	k.HolderName = ""
+	k.BorrowedAt = nil
+	k.DueAt = nil
+	k.Overdue = false
+}
+
+// KeySet groups the physical copies of a room's key
+type KeySet struct {
+	gorm.Model
+	ID           uuid.UUID `gorm:"type:uuid"`
+	RoomID       uuid.UUID `gorm:"type:uuid;foreignkey:RoomID"`
+	BuildingID   uuid.UUID `gorm:"type:uuid;foreignkey:BuildingID"`
+	RoomName     string    `json:"room_name"`
+	BuildingName string    `json:"building_name"`
 }
 
 type KeyStatus string
@@ -39,15 +115,41 @@ const (
 	KeyStatusBorrowed    KeyStatus = "borrowed"
 	KeyStatusLost        KeyStatus = "lost"
 	KeyStatusUnavailable KeyStatus = "unavailable"
+	KeyStatusMaintenance KeyStatus = "maintenance"
+	KeyStatusRetired     KeyStatus = "retired"
 )
 
+// keyTransitions lists the statuses a key may move to from each status
+var keyTransitions = map[KeyStatus][]KeyStatus{
+	KeyStatusAvailable:   {KeyStatusBorrowed, KeyStatusLost, KeyStatusMaintenance, KeyStatusRetired},
+	KeyStatusBorrowed:    {KeyStatusAvailable, KeyStatusLost},
+	KeyStatusLost:        {KeyStatusAvailable, KeyStatusRetired},
+	KeyStatusMaintenance: {KeyStatusAvailable, KeyStatusRetired},
+	KeyStatusUnavailable: {KeyStatusAvailable, KeyStatusMaintenance, KeyStatusRetired},
+	KeyStatusRetired:     {},
+}
+
+// CanTransitionTo reports whether a key may move from s to next. Keys saved
+// without a status are treated as available.
+func (s KeyStatus) CanTransitionTo(next KeyStatus) bool {
+	if s == "" {
+		s = KeyStatusAvailable
+	}
+	for _, allowed := range keyTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
 type Student struct {
 	gorm.Model
 	ID        uuid.UUID `gorm:"type:uuid"`
 	FirstName string    `json:"first_name"`
 	LastName  string    `json:"last_name"`
-	SchoolID  string    `json:"school_id"`
-	RFID      string    `json:"rfid" gorm:"column:rfid"`
+	SchoolID  string    `json:"school_id" gorm:"index"`
+	RFID      string    `json:"rfid" gorm:"column:rfid;index"`
 	College   string    `json:"college"`
 	Course    string    `json:"course"`
 	Section   string    `json:"section"`
@@ -58,18 +160,64 @@ type Instructor struct {
 	ID        uuid.UUID `gorm:"type:uuid"`
 	FirstName string    `json:"first_name"`
 	LastName  string    `json:"last_name"`
-	SchoolID  string    `json:"school_id"`
+	SchoolID  string    `json:"school_id" gorm:"index"`
+	RFID      string    `json:"rfid" gorm:"column:rfid;index"`
+	// MasterKeyAccess lets them borrow master keys
+	MasterKeyAccess bool `json:"master_key_access"`
+}
+
+type Staff struct {
+	gorm.Model
+	ID        uuid.UUID `gorm:"type:uuid"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	SchoolID  string    `json:"school_id" gorm:"index"`
+	RFID      string    `json:"rfid" gorm:"column:rfid;index"`
+	Position  string    `json:"position"`
+	// MasterKeyAccess lets them borrow master keys
+	MasterKeyAccess bool `json:"master_key_access"`
+}
+
+type Guest struct {
+	gorm.Model
+	ID          uuid.UUID `gorm:"type:uuid"`
+	FirstName   string    `json:"first_name"`
+	LastName    string    `json:"last_name"`
+	PassNumber  string    `json:"pass_number" gorm:"index"`
+	RFID        string    `json:"rfid" gorm:"column:rfid;index"`
+	Affiliation string    `json:"affiliation"`
 }
 
 type Record struct {
 	gorm.Model
 	ID           uuid.UUID  `gorm:"type:uuid"`
 	Type         RecordType `json:"type"`
-	StudentID    uuid.UUID  `gorm:"foreignkey:StudentID"`
-	KeyID        uuid.UUID  `gorm:"foreignkey:KeyID"`
+	StudentID    uuid.UUID  `gorm:"type:uuid;foreignkey:StudentID"`
+	KeyID        uuid.UUID  `gorm:"type:uuid;foreignkey:KeyID"`
 	StudentName  string
 	RoomName     string
 	BuildingName string
+	DueAt        *time.Time `json:"due_at"`
+	// The person borrowing or returning the key. StudentID and StudentName
+	// are only set when the borrower is a student.
+	BorrowerType     HolderType `json:"borrower_type"`
+	BorrowerID       uuid.UUID  `json:"borrower_id" gorm:"type:uuid"`
+	BorrowerSchoolID string     `json:"borrower_school_id"`
+	BorrowerName     string     `json:"borrower_name"`
+	Note             string     `json:"note"`
+	// Late is set on returns made after the due time
+	Late bool `json:"late"`
+	// Correction is set on records written by a key audit to fix the status
+	// of a key, they are never late
+	Correction bool `json:"correction"`
+	// The holder a transferred key was handed over from
+	FromHolderType     HolderType `json:"from_holder_type"`
+	FromHolderID       uuid.UUID  `json:"from_holder_id" gorm:"type:uuid"`
+	FromHolderSchoolID string     `json:"from_holder_school_id"`
+	FromHolderName     string     `json:"from_holder_name"`
+	// The cabinet the key was taken from or returned to
+	CabinetID   uuid.UUID `json:"cabinet_id" gorm:"type:uuid"`
+	CabinetName string    `json:"cabinet_name"`
 }
 
 type RecordType string
@@ -77,6 +225,15 @@ type RecordType string
 const (
 	RecordTypeBorrow RecordType = "borrow"
 	RecordTypeReturn RecordType = "return"
+	// A borrowed key handed over to someone else without a return
+	RecordTypeTransfer RecordType = "transfer"
+	// Key lifecycle records
+	RecordTypeLost             RecordType = "lost"
+	RecordTypeFound            RecordType = "found"
+	RecordTypeRetired          RecordType = "retired"
+	RecordTypeMaintenanceStart RecordType = "maintenance_start"
+	RecordTypeMaintenanceEnd   RecordType = "maintenance_end"
+	RecordTypeReplacement      RecordType = "replacement"
 )
 
 type Attendance struct {
@@ -87,8 +244,8 @@ type Attendance struct {
 	Course      string
 	RoomName    string
 	Subject     string
-	ScheduleID  uuid.UUID `gorm:"foreignkey:ScheduleID"`
-	StudentID   uuid.UUID `gorm:"foreignkey:StudentID"`
+	ScheduleID  uuid.UUID `gorm:"type:uuid;foreignkey:ScheduleID"`
+	StudentID   uuid.UUID `gorm:"type:uuid;foreignkey:StudentID"`
 }
 
 type Schedule struct {
@@ -100,4 +257,246 @@ type Schedule struct {
 	DayOfWeek      string    `json:"day"`
 	Subject        string    `json:"subject"`
 	InstructorName string    `json:"instructor"`
+	Course         string    `json:"course"`
+	Section        string    `json:"section"`
+}
+
+type Reservation struct {
+	gorm.Model
+	ID             uuid.UUID         `gorm:"type:uuid"`
+	KeyID          uuid.UUID         `gorm:"type:uuid;foreignkey:KeyID"`
+	ScheduleID     uuid.UUID         `json:"schedule_id" gorm:"type:uuid;foreignkey:ScheduleID"`
+	HolderType     HolderType        `json:"holder_type"`
+	HolderID       uuid.UUID         `json:"holder_id" gorm:"type:uuid"`
+	HolderSchoolID string            `json:"school_id"`
+	HolderName     string            `json:"holder_name"`
+	KeyRFID        string            `json:"key_rfid"`
+	RoomName       string            `json:"room_name"`
+	BuildingName   string            `json:"building_name"`
+	StartsAt       time.Time         `json:"starts_at"`
+	EndsAt         time.Time         `json:"ends_at"`
+	Status         ReservationStatus `json:"status"`
+}
+
+type HolderType string
+
+const (
+	HolderTypeStudent    HolderType = "student"
+	HolderTypeInstructor HolderType = "instructor"
+	HolderTypeStaff      HolderType = "staff"
+	HolderTypeGuest      HolderType = "guest"
+)
+
+type ReservationStatus string
+
+const (
+	ReservationStatusActive    ReservationStatus = "active"
+	ReservationStatusFulfilled ReservationStatus = "fulfilled"
+	ReservationStatusCancelled ReservationStatus = "cancelled"
+)
+
+// WaitlistEntry is a place in the queue for a borrowed key. When the key is
+// returned the first one waiting is given a hold on it until HoldUntil.
+type WaitlistEntry struct {
+	gorm.Model
+	ID             uuid.UUID      `gorm:"type:uuid"`
+	KeyID          uuid.UUID      `json:"key_id" gorm:"type:uuid;index"`
+	KeyRFID        string         `json:"key_rfid"`
+	RoomName       string         `json:"room_name"`
+	BuildingName   string         `json:"building_name"`
+	HolderType     HolderType     `json:"holder_type"`
+	HolderID       uuid.UUID      `json:"holder_id" gorm:"type:uuid"`
+	HolderSchoolID string         `json:"school_id"`
+	HolderName     string         `json:"holder_name"`
+	Status         WaitlistStatus `json:"status"`
+	HoldUntil      *time.Time     `json:"hold_until"`
+	NotifiedAt     *time.Time     `json:"notified_at"`
+}
+
+type WaitlistStatus string
+
+const (
+	WaitlistStatusWaiting   WaitlistStatus = "waiting"
+	WaitlistStatusHolding   WaitlistStatus = "holding"
+	WaitlistStatusFulfilled WaitlistStatus = "fulfilled"
+	WaitlistStatusExpired   WaitlistStatus = "expired"
+	WaitlistStatusCancelled WaitlistStatus = "cancelled"
+)
+
+// BorrowLimitException lifts the borrow limits of a student, in one building
+// or everywhere when BuildingID is empty
+type BorrowLimitException struct {
+	gorm.Model
+	ID            uuid.UUID  `gorm:"type:uuid"`
+	StudentID     uuid.UUID  `json:"student_id" gorm:"type:uuid;foreignkey:StudentID"`
+	SchoolID      string     `json:"school_id"`
+	StudentName   string     `json:"student_name"`
+	BuildingID    uuid.UUID  `json:"building_id" gorm:"type:uuid;foreignkey:BuildingID"`
+	BuildingName  string     `json:"building_name"`
+	MaxKeys       int        `json:"max_keys"`
+	WaiveCooldown bool       `json:"waive_cooldown"`
+	ExpiresAt     *time.Time `json:"expires_at"`
+	Reason        string     `json:"reason"`
+	GrantedBy     string     `json:"granted_by"`
+}
+
+// RoomAccessGrant lets a student or instructor borrow the keys of a
+// restricted room until ExpiresAt, or until it is revoked when ExpiresAt is nil
+type RoomAccessGrant struct {
+	gorm.Model
+	ID             uuid.UUID     `gorm:"type:uuid"`
+	RoomID         uuid.UUID     `json:"room_id" gorm:"type:uuid;index"`
+	RoomName       string        `json:"room_name"`
+	BuildingName   string        `json:"building_name"`
+	HolderType     HolderType    `json:"holder_type"`
+	HolderID       uuid.UUID     `json:"holder_id" gorm:"type:uuid;index"`
+	HolderSchoolID string        `json:"school_id"`
+	HolderName     string        `json:"holder_name"`
+	ExpiresAt      *time.Time    `json:"expires_at"`
+	Reason         string        `json:"reason"`
+	GrantedByType  GrantedByType `json:"granted_by_type"`
+	GrantedByID    uuid.UUID     `json:"granted_by_id" gorm:"type:uuid"`
+	GrantedBy      string        `json:"granted_by"`
+}
+
+// GrantedByType is who issued an access grant
+type GrantedByType string
+
+const (
+	GrantedByInstructor GrantedByType = "instructor"
+	GrantedByAdmin      GrantedByType = "admin"
+)
+
+// Suspension blocks a student from borrowing keys from StartsAt until EndsAt,
+// or until it is lifted when EndsAt is empty
+type Suspension struct {
+	gorm.Model
+	ID          uuid.UUID        `gorm:"type:uuid"`
+	StudentID   uuid.UUID        `json:"student_id" gorm:"type:uuid;foreignkey:StudentID;index"`
+	SchoolID    string           `json:"school_id"`
+	StudentName string           `json:"student_name"`
+	Source      SuspensionSource `json:"source"`
+	Reason      string           `json:"reason"`
+	StartsAt    time.Time        `json:"starts_at"`
+	EndsAt      *time.Time       `json:"ends_at"`
+	CreatedBy   string           `json:"created_by"`
+	LiftedAt    *time.Time       `json:"lifted_at"`
+	LiftedBy    string           `json:"lifted_by"`
+}
+
+type SuspensionSource string
+
+const (
+	SuspensionSourceManual      SuspensionSource = "manual"
+	SuspensionSourceLateReturns SuspensionSource = "late_returns"
+	SuspensionSourceLostKeys    SuspensionSource = "lost_keys"
+)
+
+// SuspensionEvent is the audit trail of a suspension
+type SuspensionEvent struct {
+	gorm.Model
+	ID           uuid.UUID             `gorm:"type:uuid"`
+	SuspensionID uuid.UUID             `json:"suspension_id" gorm:"type:uuid;index"`
+	Action       SuspensionEventAction `json:"action"`
+	By           string                `json:"by"`
+	Reason       string                `json:"reason"`
+}
+
+type SuspensionEventAction string
+
+const (
+	SuspensionEventCreated SuspensionEventAction = "created"
+	SuspensionEventLifted  SuspensionEventAction = "lifted"
+)
+
+// KeyAudit is a count of the key tags found hanging in a building's key box
+// compared with what the keys' statuses say
+type KeyAudit struct {
+	gorm.Model
+	ID              uuid.UUID  `gorm:"type:uuid"`
+	BuildingID      uuid.UUID  `json:"building_id" gorm:"type:uuid;foreignkey:BuildingID"`
+	BuildingName    string     `json:"building_name"`
+	AuditedBy       string     `json:"audited_by"`
+	Note            string     `json:"note"`
+	Scanned         int        `json:"scanned"`
+	Matched         int        `json:"matched"`
+	Missing         int        `json:"missing"`
+	PresentBorrowed int        `json:"present_borrowed"`
+	PresentLost     int        `json:"present_lost"`
+	Unknown         int        `json:"unknown"`
+	AppliedAt       *time.Time `json:"applied_at"`
+	AppliedBy       string     `json:"applied_by"`
+}
+
+// KeyAuditFinding is a key or tag whose presence does not match its status
+type KeyAuditFinding struct {
+	gorm.Model
+	ID         uuid.UUID        `gorm:"type:uuid"`
+	AuditID    uuid.UUID        `json:"audit_id" gorm:"type:uuid;index"`
+	Kind       AuditFindingKind `json:"kind"`
+	KeyID      uuid.UUID        `json:"key_id" gorm:"type:uuid"`
+	RFID       string           `json:"rfid" gorm:"column:rfid"`
+	RoomName   string           `json:"room_name"`
+	KeyStatus  KeyStatus        `json:"key_status"`
+	HolderName string           `json:"holder_name"`
+	Note       string           `json:"note"`
+	Resolution AuditResolution  `json:"resolution"`
+	RecordID   uuid.UUID        `json:"record_id" gorm:"type:uuid"`
+	ResolvedAt *time.Time       `json:"resolved_at"`
+}
+
+type AuditFindingKind string
+
+const (
+	// An available key that was not in the box
+	AuditFindingMissing AuditFindingKind = "missing"
+	// A borrowed key that was in the box
+	AuditFindingPresentBorrowed AuditFindingKind = "present_borrowed"
+	// A lost key that was in the box
+	AuditFindingPresentLost AuditFindingKind = "present_lost"
+	// A tag that is not a key of the building
+	AuditFindingUnknown AuditFindingKind = "unknown"
+)
+
+type AuditResolution string
+
+const (
+	AuditResolutionCorrected AuditResolution = "corrected"
+	AuditResolutionSkipped   AuditResolution = "skipped"
+)
+
+// AccessGroup is the set of rooms opened by a master key. It covers a whole
+// building, one floor of it, or the rooms listed in AccessGroupRoom.
+type AccessGroup struct {
+	gorm.Model
+	ID           uuid.UUID        `gorm:"type:uuid"`
+	Name         string           `json:"name"`
+	BuildingID   uuid.UUID        `json:"building_id" gorm:"type:uuid;foreignkey:BuildingID"`
+	BuildingName string           `json:"building_name"`
+	Scope        AccessGroupScope `json:"scope"`
+	Floor        int              `json:"floor"`
+}
+
+type AccessGroupScope string
+
+const (
+	AccessGroupScopeBuilding AccessGroupScope = "building"
+	AccessGroupScopeFloor    AccessGroupScope = "floor"
+	AccessGroupScopeRooms    AccessGroupScope = "rooms"
+)
+
+// AccessGroupRoom is a room listed in an access group of the rooms scope
+type AccessGroupRoom struct {
+	gorm.Model
+	ID            uuid.UUID `gorm:"type:uuid"`
+	AccessGroupID uuid.UUID `json:"access_group_id" gorm:"type:uuid;index"`
+	RoomID        uuid.UUID `json:"room_id" gorm:"type:uuid"`
+	RoomName      string    `json:"room_name"`
+}
+
+// IdempotentResponse is a response stored for the Idempotency-Key it was made with
+type IdempotentResponse struct {
+	Key       string `gorm:"primaryKey"`
+	Value     []byte
+	ExpiresAt *time.Time `gorm:"index"`
 }
@@ -0,0 +1,53 @@
+package notify
+
+import (
+	"log"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/vincemoke66/keyper-api/internals/model"
+)
+
+// Message is a notice for one borrower
+type Message struct {
+	HolderType model.HolderType
+	HolderID   uuid.UUID
+	SchoolID   string
+	Name       string
+	Subject    string
+	Body       string
+}
+
+// Notifier delivers messages to borrowers, by mail, sms or whatever the
+// school uses. Use sets the one the api sends with.
+type Notifier interface {
+	Notify(message Message) error
+}
+
+// LogNotifier writes messages to the log, it is used until another one is set
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(message Message) error {
+	log.Printf("Notify %s (%s): %s - %s", message.Name, message.SchoolID, message.Subject, message.Body)
+	return nil
+}
+
+var (
+	mu      sync.RWMutex
+	current Notifier = LogNotifier{}
+)
+
+// Use makes n the notifier messages are sent with
+func Use(n Notifier) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = n
+}
+
+// Send delivers message with the current notifier
+func Send(message Message) error {
+	mu.RLock()
+	n := current
+	mu.RUnlock()
+	return n.Notify(message)
+}
@@ -0,0 +1,25 @@
+package accessGroupRoutes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	accessGroupHandler "github.com/vincemoke66/keyper-api/internals/handlers/accessgroup"
+)
+
+func SetupStudentRoutes(router fiber.Router) {
+	accessGroup := router.Group("/access-group")
+
+	// Create an access group
+	accessGroup.Post("/", accessGroupHandler.CreateAccessGroup)
+
+	// Read all access groups
+	accessGroup.Get("/", accessGroupHandler.GetAccessGroups)
+
+	// Read one access group with its rooms and master keys
+	accessGroup.Get("/:name", accessGroupHandler.GetAccessGroup)
+
+	// Update one access group
+	accessGroup.Put("/:name", accessGroupHandler.UpdateAccessGroup)
+
+	// Delete one access group
+	accessGroup.Delete("/:name", accessGroupHandler.DeleteAccessGroup)
+}
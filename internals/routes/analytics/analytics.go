@@ -0,0 +1,13 @@
+package analyticsRoutes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	analyticsHandler "github.com/vincemoke66/keyper-api/internals/handlers/analytics"
+)
+
+func SetupStudentRoutes(router fiber.Router) {
+	analytics := router.Group("/analytics")
+
+	// Read the usage statistics of keys
+	analytics.Get("/keys", analyticsHandler.GetKeyAnalytics)
+}
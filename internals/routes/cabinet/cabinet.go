@@ -0,0 +1,23 @@
+package cabinetRoutes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	cabinetHandler "github.com/vincemoke66/keyper-api/internals/handlers/cabinet"
+)
+
+func SetupStudentRoutes(router fiber.Router) {
+	cabinet := router.Group("/cabinet")
+
+	// Create a cabinet
+	cabinet.Post("/", cabinetHandler.CreateCabinet)
+	// Read all cabinets
+	cabinet.Get("/", cabinetHandler.GetCabinets)
+	// Read a cabinet
+	cabinet.Get("/:name", cabinetHandler.GetCabinet)
+	// Read the current contents of a cabinet
+	cabinet.Get("/:name/keys", cabinetHandler.GetCabinetKeys)
+	// Update cabinet
+	cabinet.Put("/:name", cabinetHandler.UpdateCabinet)
+	// Delete a cabinet
+	cabinet.Delete("/:name", cabinetHandler.DeleteCabinet)
+}
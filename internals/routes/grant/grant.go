@@ -0,0 +1,17 @@
+package grantRoutes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	grantHandler "github.com/vincemoke66/keyper-api/internals/handlers/grant"
+)
+
+func SetupStudentRoutes(router fiber.Router) {
+	grant := router.Group("/grant")
+
+	// Grant a student or instructor access to a restricted room
+	grant.Post("/", grantHandler.CreateGrant)
+	// Read all grants
+	grant.Get("/", grantHandler.GetGrants)
+	// Revoke a grant
+	grant.Delete("/:id", grantHandler.DeleteGrant)
+}
@@ -0,0 +1,21 @@
+package guestRoutes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	guestHandler "github.com/vincemoke66/keyper-api/internals/handlers/guest"
+)
+
+func SetupStudentRoutes(router fiber.Router) {
+	guest := router.Group("/guest")
+
+	// Create a guest
+	guest.Post("/", guestHandler.CreateGuest)
+	// Read all guests
+	guest.Get("/", guestHandler.GetGuests)
+	// Read a guest
+	guest.Get("/:pass_number", guestHandler.GetGuest)
+	// Update guest
+	guest.Put("/:pass_number", guestHandler.UpdateGuest)
+	// Delete a guest
+	guest.Delete("/:pass_number", guestHandler.DeleteGuest)
+}
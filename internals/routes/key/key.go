@@ -14,12 +14,50 @@ func SetupStudentRoutes(router fiber.Router) {
 	// Read all keys in a specific building
 	key.Get("/", keyHandler.GetKeys)
 
+	// Read all overdue keys
+	key.Get("/overdue", keyHandler.GetOverdueKeys)
+
 	// Read all keys in a specific building
 	key.Get("/rfid/:rfid", keyHandler.GetKeyUsingRFID)
 
+	// Read who currently holds a key
+	key.Get("/rfid/:rfid/holder", keyHandler.GetKeyHolder)
+
+	// Read the chain of custody of a key
+	key.Get("/rfid/:rfid/history", keyHandler.GetKeyHistory)
+
+	// Queue for a borrowed key or leave its queue
+	key.Get("/rfid/:rfid/waitlist", keyHandler.GetKeyWaitlist)
+	key.Post("/rfid/:rfid/waitlist", keyHandler.JoinKeyWaitlist)
+	key.Delete("/rfid/:rfid/waitlist/:id", keyHandler.LeaveKeyWaitlist)
+
+	// Report a key lost, mark it found or retire it
+	key.Post("/rfid/:rfid/lost", keyHandler.ReportKeyLost)
+	key.Post("/rfid/:rfid/found", keyHandler.MarkKeyFound)
+	key.Post("/rfid/:rfid/retire", keyHandler.RetireKey)
+
+	// Put a key into or take it out of maintenance
+	key.Post("/rfid/:rfid/maintenance", keyHandler.StartKeyMaintenance)
+	key.Delete("/rfid/:rfid/maintenance", keyHandler.EndKeyMaintenance)
+
+	// Replace a lost key with a new one
+	key.Post("/rfid/:rfid/replace", keyHandler.ReplaceKey)
+
+	// Audit the keys found in a building's key box and apply the corrections
+	key.Post("/audit", keyHandler.CreateKeyAudit)
+	key.Get("/audit", keyHandler.GetKeyAudits)
+	key.Get("/audit/:id", keyHandler.GetKeyAudit)
+	key.Post("/audit/:id/apply", keyHandler.ApplyKeyAudit)
+
 	// Read all keys in a specific building
 	key.Get("/bn/:building_name", keyHandler.GetKeysUsingBuildingName)
 
+	// Read the key copies of every room in a specific building
+	key.Get("/bn/:building_name/rooms", keyHandler.GetKeySetsUsingBuildingName)
+
+	// Read the key copies of a specific room
+	key.Get("/room/:room_name", keyHandler.GetKeySetUsingRoomName)
+
 	// Read a key
 	// key.Get("/:name", keyHandler.GetRoom)
 
@@ -0,0 +1,17 @@
+package limitRoutes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	limitHandler "github.com/vincemoke66/keyper-api/internals/handlers/limit"
+)
+
+func SetupStudentRoutes(router fiber.Router) {
+	limit := router.Group("/limit")
+
+	// Grant a student an exception to the borrow limits
+	limit.Post("/exception", limitHandler.CreateException)
+	// Read all active exceptions
+	limit.Get("/exception", limitHandler.GetExceptions)
+	// Delete an exception
+	limit.Delete("/exception/:id", limitHandler.DeleteException)
+}
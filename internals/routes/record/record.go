@@ -10,7 +10,12 @@ func SetupStudentRoutes(router fiber.Router) {
 
 	// Create a record
 	record.Post("/", recordHandler.CreateRecord)
+	// Borrow or return several keys at once
+	record.Post("/batch", recordHandler.CreateBatchRecord)
 
 	// Read all records
 	record.Get("/", recordHandler.GetAllRecords)
+
+	// Export borrows with their returns as csv or xlsx
+	record.Get("/export", recordHandler.ExportRecords)
 }
@@ -0,0 +1,19 @@
+package reservationRoutes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	reservationHandler "github.com/vincemoke66/keyper-api/internals/handlers/reservation"
+)
+
+func SetupStudentRoutes(router fiber.Router) {
+	reservation := router.Group("/reservation")
+
+	// Create a reservation
+	reservation.Post("/", reservationHandler.CreateReservation)
+	// Read all reservations
+	reservation.Get("/", reservationHandler.GetReservations)
+	// Read the active reservations of a key
+	reservation.Get("/rfid/:rfid", reservationHandler.GetReservationsUsingRFID)
+	// Cancel a reservation
+	reservation.Delete("/:id", reservationHandler.CancelReservation)
+}
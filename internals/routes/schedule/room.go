@@ -12,4 +12,6 @@ func SetupStudentRoutes(router fiber.Router) {
 	schedule.Post("/", scheduleHandler.CreateSchedule)
 	// Read all rooms
 	schedule.Get("/", scheduleHandler.GetSchedules)
+	// Update one schedule
+	schedule.Put("/:id", scheduleHandler.UpdateSchedule)
 }
@@ -0,0 +1,21 @@
+package staffRoutes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	staffHandler "github.com/vincemoke66/keyper-api/internals/handlers/staff"
+)
+
+func SetupStudentRoutes(router fiber.Router) {
+	staff := router.Group("/staff")
+
+	// Create a staff member
+	staff.Post("/", staffHandler.CreateStaff)
+	// Read all staff
+	staff.Get("/", staffHandler.GetAllStaff)
+	// Read a staff member
+	staff.Get("/:school_id", staffHandler.GetStaff)
+	// Update staff member
+	staff.Put("/:school_id", staffHandler.UpdateStaff)
+	// Delete a staff member
+	staff.Delete("/:school_id", staffHandler.DeleteStaff)
+}
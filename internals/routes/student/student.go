@@ -12,6 +12,9 @@ func SetupStudentRoutes(router fiber.Router) {
 	student.Post("/", studentHandler.CreateStudent)
 	// Read all students
 	student.Get("/", studentHandler.GetStudents)
+	// Read the keys a student currently holds through school id or rfid
+	student.Get("/school_id/:school_id/keys", studentHandler.GetStudentKeys)
+	student.Get("/rfid/:rfid/keys", studentHandler.GetStudentKeysThroughRFID)
 	// Read a student through rfid
 	student.Get("/:rfid", studentHandler.GetStudentThroughRFID)
 	// Read a student through school id
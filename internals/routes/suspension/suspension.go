@@ -0,0 +1,19 @@
+package suspensionRoutes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	suspensionHandler "github.com/vincemoke66/keyper-api/internals/handlers/suspension"
+)
+
+func SetupStudentRoutes(router fiber.Router) {
+	suspension := router.Group("/suspension")
+
+	// Suspend a student
+	suspension.Post("/", suspensionHandler.CreateSuspension)
+	// Read all suspensions
+	suspension.Get("/", suspensionHandler.GetSuspensions)
+	// Read a suspension and its audit trail
+	suspension.Get("/:id", suspensionHandler.GetSuspension)
+	// Lift a suspension
+	suspension.Post("/:id/lift", suspensionHandler.LiftSuspension)
+}
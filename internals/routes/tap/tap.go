@@ -0,0 +1,18 @@
+package tapRoutes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	tapHandler "github.com/vincemoke66/keyper-api/internals/handlers/tap"
+)
+
+func SetupStudentRoutes(router fiber.Router) {
+	tap := router.Group("/tap")
+
+	// Borrow or return a key depending on its state
+	tap.Post("/", tapHandler.CreateTap)
+
+	// Tap a card or a key on a reader
+	tap.Post("/device/:device_id", tapHandler.CreateReaderTap)
+	// Cancel the session opened on a reader
+	tap.Delete("/device/:device_id", tapHandler.CancelReaderSession)
+}
@@ -0,0 +1,58 @@
+// Package testdb gives tests a database to run against
+package testdb
+
+import (
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/vincemoke66/keyper-api/database"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var (
+	once sync.Once
+	db   *gorm.DB
+	err  error
+)
+
+// Begin starts a transaction on the postgres database in TEST_DATABASE_DSN and
+// makes it database.DB until the test ends, then rolls it back. Transactions
+// of the handlers become savepoints of it. The test is skipped when
+// TEST_DATABASE_DSN is unset.
+func Begin(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	// Connect and migrate once for all the tests of the package
+	once.Do(func() {
+		db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+		if err != nil {
+			return
+		}
+		database.DB = db
+		database.Migrate()
+	})
+	if err != nil {
+		t.Fatal("Failed to connect to the test database:", err)
+	}
+
+	tx := db.Begin()
+	if tx.Error != nil {
+		t.Fatal(tx.Error)
+	}
+
+	previous := database.DB
+	database.DB = tx
+	t.Cleanup(func() {
+		database.DB = previous
+		tx.Rollback()
+	})
+	return tx
+}
@@ -0,0 +1,160 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Writer streams a single sheet spreadsheet. Rows are written to the
+// underlying writer as they come instead of being kept in memory.
+type Writer struct {
+	zip   *zip.Writer
+	sheet io.Writer
+	rows  int
+}
+
+// NewWriter starts a workbook with one sheet of the given name on w
+func NewWriter(w io.Writer, sheetName string) (*Writer, error) {
+	z := zip.NewWriter(w)
+
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", contentTypes},
+		{"_rels/.rels", rootRels},
+		{"xl/workbook.xml", fmt.Sprintf(workbook, escape(sheetName))},
+		{"xl/_rels/workbook.xml.rels", workbookRels},
+	}
+	for _, part := range parts {
+		f, err := z.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	sheet, err := z.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(sheet, sheetStart); err != nil {
+		return nil, err
+	}
+
+	return &Writer{zip: z, sheet: sheet}, nil
+}
+
+// WriteRow adds a row of cells. Numbers are written as numbers, times as
+// RFC3339 text, nil as an empty cell and everything else as text.
+func (w *Writer) WriteRow(cells ...interface{}) error {
+	w.rows++
+	if _, err := fmt.Fprintf(w.sheet, `<row r="%d">`, w.rows); err != nil {
+		return err
+	}
+
+	for i, cell := range cells {
+		ref := columnName(i) + strconv.Itoa(w.rows)
+
+		var err error
+		switch v := cell.(type) {
+		case nil:
+			continue
+		case int:
+			_, err = fmt.Fprintf(w.sheet, `<c r="%s"><v>%d</v></c>`, ref, v)
+		case int64:
+			_, err = fmt.Fprintf(w.sheet, `<c r="%s"><v>%d</v></c>`, ref, v)
+		case float64:
+			_, err = fmt.Fprintf(w.sheet, `<c r="%s"><v>%s</v></c>`, ref, strconv.FormatFloat(v, 'f', -1, 64))
+		case time.Time:
+			_, err = fmt.Fprintf(w.sheet, `<c r="%s" t="inlineStr"><is><t>%s</t></is></c>`, ref, v.Format(time.RFC3339))
+		default:
+			_, err = fmt.Fprintf(w.sheet, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, escape(fmt.Sprint(v)))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(w.sheet, "</row>")
+	return err
+}
+
+// Close ends the sheet and the workbook. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if _, err := io.WriteString(w.sheet, sheetEnd); err != nil {
+		return err
+	}
+	return w.zip.Close()
+}
+
+// columnName turns a zero based column index into A, B, ..., Z, AA, ...
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// escape makes s safe to put in a cell. Control characters xml cannot hold
+// are written as _xHHHH_, the way spreadsheets store them, and text that
+// already looks like such an escape has its underscore escaped.
+func escape(s string) string {
+	var text strings.Builder
+	for i, r := range s {
+		switch {
+		case r < 0x20 && r != '\t' && r != '\n' && r != '\r':
+			fmt.Fprintf(&text, "_x%04X_", r)
+		case r == '_' && isEscapedChar(s[i:]):
+			text.WriteString("_x005F_")
+		default:
+			text.WriteRune(r)
+		}
+	}
+
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(text.String()))
+	return b.String()
+}
+
+// isEscapedChar tells if s starts with an _xHHHH_ escape
+func isEscapedChar(s string) bool {
+	if len(s) < 7 || s[0] != '_' || s[1] != 'x' || s[6] != '_' {
+		return false
+	}
+	_, err := strconv.ParseUint(s[2:6], 16, 16)
+	return err == nil
+}
+
+const contentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+</Types>`
+
+const rootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+
+const workbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`
+
+const workbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+</Relationships>`
+
+const sheetStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+
+const sheetEnd = `</sheetData></worksheet>`
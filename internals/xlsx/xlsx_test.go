@@ -0,0 +1,151 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type sheetXML struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			R    string `xml:"r,attr"`
+			T    string `xml:"t,attr"`
+			V    string `xml:"v"`
+			Text string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+type workbookXML struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+var escapedChar = regexp.MustCompile(`_x([0-9A-Fa-f]{4})_`)
+
+// unescape decodes the _xHHHH_ escapes the way spreadsheet readers do
+func unescape(s string) string {
+	return escapedChar.ReplaceAllStringFunc(s, func(m string) string {
+		r, _ := strconv.ParseUint(m[2:6], 16, 16)
+		return string(rune(r))
+	})
+}
+
+// readPart parses one part of the workbook in buf into v
+func readPart(t *testing.T, buf []byte, name string, v interface{}) {
+	t.Helper()
+
+	z, err := zip.NewReader(bytes.NewReader(buf), int64(len(buf)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := z.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	content, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := xml.Unmarshal(content, v); err != nil {
+		t.Fatalf("%s is not valid xml: %v", name, err)
+	}
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	texts := []string{
+		`<b>&"quoted" 'single'</b>`,
+		"line\nbreak\ttab\r",
+		"bell\x07 null\x00 escape\x1b",
+		"_x0041_ stays literal",
+		"  spaced  ",
+		"ünïcödé 鍵",
+	}
+	at := time.Date(2023, 5, 1, 8, 30, 0, 0, time.UTC)
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, `Borrows <&> "2023"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One text per cell, then numbers, a gap and a time
+	row := []interface{}{}
+	for _, text := range texts {
+		row = append(row, text)
+	}
+	row = append(row, 42, int64(7), 1.5, nil, at)
+	if err := w.WriteRow(row...); err != nil {
+		t.Fatal(err)
+	}
+
+	// A row wide enough to go past column Z
+	wide := make([]interface{}, 30)
+	for i := range wide {
+		wide[i] = i
+	}
+	if err := w.WriteRow(wide...); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var book workbookXML
+	readPart(t, buf.Bytes(), "xl/workbook.xml", &book)
+	if len(book.Sheets) != 1 || book.Sheets[0].Name != `Borrows <&> "2023"` {
+		t.Errorf("sheet names = %+v", book.Sheets)
+	}
+
+	var sheet sheetXML
+	readPart(t, buf.Bytes(), "xl/worksheets/sheet1.xml", &sheet)
+	if len(sheet.Rows) != 2 {
+		t.Fatalf("got %d rows, want 2", len(sheet.Rows))
+	}
+
+	cells := sheet.Rows[0].Cells
+	if len(cells) != len(texts)+4 {
+		t.Fatalf("got %d cells in the first row, want %d", len(cells), len(texts)+4)
+	}
+	for i, text := range texts {
+		if cells[i].T != "inlineStr" || unescape(cells[i].Text) != text {
+			t.Errorf("cell %s = %q (%s), want %q", cells[i].R, unescape(cells[i].Text), cells[i].T, text)
+		}
+	}
+	numbers := cells[len(texts) : len(texts)+3]
+	for i, want := range []string{"42", "7", "1.5"} {
+		if numbers[i].T != "" || numbers[i].V != want {
+			t.Errorf("cell %s = %q (%s), want the number %s", numbers[i].R, numbers[i].V, numbers[i].T, want)
+		}
+	}
+	// The nil cell is left out, so the time lands one column further
+	last := cells[len(cells)-1]
+	if last.R != columnName(len(row)-1)+"1" || last.Text != "2023-05-01T08:30:00Z" {
+		t.Errorf("time cell %s = %q", last.R, last.Text)
+	}
+
+	for i, cell := range sheet.Rows[1].Cells {
+		if want := columnName(i) + "2"; cell.R != want || cell.V != strconv.Itoa(i) {
+			t.Errorf("cell %s = %q, want %s = %d", cell.R, cell.V, want, i)
+		}
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	cases := map[int]string{0: "A", 1: "B", 25: "Z", 26: "AA", 27: "AB", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA", 16383: "XFD"}
+	for i, want := range cases {
+		if got := columnName(i); got != want {
+			t.Errorf("columnName(%d) = %s, want %s", i, got, want)
+		}
+	}
+}
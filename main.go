@@ -3,6 +3,7 @@ package main
 import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/vincemoke66/keyper-api/database"
+	"github.com/vincemoke66/keyper-api/internals/jobs"
 	"github.com/vincemoke66/keyper-api/router"
 )
 
@@ -12,6 +13,9 @@ func main() {
 	// Connect to the Database
 	database.ConnectDB()
 
+	// Start the background jobs
+	jobs.Start()
+
 	// Setup the router
 	router.SetupRoutes(app)
 
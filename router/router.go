@@ -2,25 +2,49 @@ package router
 
 import (
 	"github.com/gofiber/fiber/v2"
+	"github.com/vincemoke66/keyper-api/internals/middleware"
+	accessGroupRoutes "github.com/vincemoke66/keyper-api/internals/routes/accessgroup"
+	analyticsRoutes "github.com/vincemoke66/keyper-api/internals/routes/analytics"
 	attendanceRoutes "github.com/vincemoke66/keyper-api/internals/routes/attendance"
 	buildingRoutes "github.com/vincemoke66/keyper-api/internals/routes/building"
+	cabinetRoutes "github.com/vincemoke66/keyper-api/internals/routes/cabinet"
+	grantRoutes "github.com/vincemoke66/keyper-api/internals/routes/grant"
+	guestRoutes "github.com/vincemoke66/keyper-api/internals/routes/guest"
 	instructorRoutes "github.com/vincemoke66/keyper-api/internals/routes/instructor"
 	keyRoutes "github.com/vincemoke66/keyper-api/internals/routes/key"
+	limitRoutes "github.com/vincemoke66/keyper-api/internals/routes/limit"
 	recordRoutes "github.com/vincemoke66/keyper-api/internals/routes/record"
+	reservationRoutes "github.com/vincemoke66/keyper-api/internals/routes/reservation"
 	roomRoutes "github.com/vincemoke66/keyper-api/internals/routes/room"
 	scheduleRoutes "github.com/vincemoke66/keyper-api/internals/routes/schedule"
+	staffRoutes "github.com/vincemoke66/keyper-api/internals/routes/staff"
 	studentRoutes "github.com/vincemoke66/keyper-api/internals/routes/student"
+	suspensionRoutes "github.com/vincemoke66/keyper-api/internals/routes/suspension"
+	tapRoutes "github.com/vincemoke66/keyper-api/internals/routes/tap"
 )
 
 func SetupRoutes(app *fiber.App) {
 	api := app.Group("api")
 
+	// Replay retried create requests carrying the same Idempotency-Key
+	api.Use(middleware.Idempotency())
+
 	studentRoutes.SetupStudentRoutes(api)
 	instructorRoutes.SetupStudentRoutes(api)
+	staffRoutes.SetupStudentRoutes(api)
+	guestRoutes.SetupStudentRoutes(api)
 	buildingRoutes.SetupStudentRoutes(api)
 	roomRoutes.SetupStudentRoutes(api)
+	cabinetRoutes.SetupStudentRoutes(api)
+	accessGroupRoutes.SetupStudentRoutes(api)
 	keyRoutes.SetupStudentRoutes(api)
 	recordRoutes.SetupStudentRoutes(api)
+	tapRoutes.SetupStudentRoutes(api)
 	attendanceRoutes.SetupStudentRoutes(api)
 	scheduleRoutes.SetupStudentRoutes(api)
+	reservationRoutes.SetupStudentRoutes(api)
+	analyticsRoutes.SetupStudentRoutes(api)
+	limitRoutes.SetupStudentRoutes(api)
+	suspensionRoutes.SetupStudentRoutes(api)
+	grantRoutes.SetupStudentRoutes(api)
 }